@@ -1,33 +1,113 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"image"
+	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/roman-mazur/architecture-lab-3/painter"
 	"github.com/roman-mazur/architecture-lab-3/painter/lang"
 	"github.com/roman-mazur/architecture-lab-3/ui"
+	"golang.org/x/exp/shiny/screen"
+)
+
+var (
+	headless = flag.Bool("headless", false, "run the command server without a window")
+	width    = flag.Int("width", Painter.DefaultSize.X, "canvas width in pixels")
+	height   = flag.Int("height", Painter.DefaultSize.Y, "canvas height in pixels")
+	scenes   = flag.String("scenes", "scenes", "directory for scenes stored by the save command")
+	queue    = flag.Int("queue", Painter.DefaultQueueCapacity, "maximum number of queued operations")
+	fps      = flag.Int("fps", 60, "maximum frames per second, 0 disables the limit")
+	output   = flag.String("o", "", "render the scripts to a PNG file and exit without starting the server")
 )
 
 func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [script ...]\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Scripts are executed at startup, \"-\" reads a script from stdin.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *width < 1 || *height < 1 || *width > Lang.MaxCanvasSize || *height > Lang.MaxCanvasSize {
+		log.Fatalf("Canvas size must be in [1,%d] range", Lang.MaxCanvasSize)
+	}
+
+	size := image.Pt(*width, *height)
+	loopOptions := []Painter.LoopOption{
+		Painter.WithSize(size),
+		Painter.WithQueueCapacity(*queue),
+		Painter.WithMaxFPS(*fps),
+	}
+
 	var (
 		pv ui.Visualizer // Візуалізатор створює вікно та малює у ньому.
 
 		// Потрібні для частини 2.
-		opLoop Painter.Loop // Цикл обробки команд.
-		parser Lang.Parser  // Парсер команд.
+		opLoop = Painter.NewLoop(loopOptions...) // Цикл обробки команд.
+		parser Lang.Parser                       // Парсер команд.
+		images Lang.ImageRegistry                // Зображення для команди image.
+
+		recorder    Painter.Recorder    // Зберігає останній кадр для /snapshot.
+		broadcaster Painter.Broadcaster // Розсилає кадри клієнтам /stream.
 	)
 
-	//pv.Debug = true
-	pv.Title = "Simple Painter"
+	parser.Images = &images
+	parser.ScenesDir = *scenes
+
+	ops, err := runScripts(&parser, flag.Args())
+	if err != nil {
+		log.Fatalf("Bad script: %s", err)
+	}
+	if *output != "" {
+		if err := renderPNG(ops, size, *output); err != nil {
+			log.Fatalf("Failed to render %s: %s", *output, err)
+		}
+		return
+	}
+
+	http.Handle("/", Lang.HttpHandler(opLoop, &parser))
+	http.Handle("/images/{name}", Lang.ImageUploadHandler(&images))
+	http.Handle("/undo", Lang.UndoHandler(opLoop, &parser))
+	http.Handle("/redo", Lang.RedoHandler(opLoop, &parser))
+	http.Handle("/snapshot", Lang.SnapshotHandler(&recorder))
+	http.Handle("/stats", Lang.StatsHandler(opLoop))
+	http.Handle("/stream", Lang.StreamHandler(&broadcaster))
+
+	// Операції скриптів відправляються після запуску циклу, бо Post чекає, поки в черзі з'явиться місце. Сервер
+	// запускається лише після них, щоб старіші стани скриптів не потрапили в чергу після команд з HTTP запитів.
+	start := func() {
+		opLoop.Start(Painter.SoftScreen{})
+		for _, op := range ops {
+			opLoop.Post(op)
+		}
+		go func() {
+			_ = http.ListenAndServe("localhost:17000", nil)
+		}()
+	}
+
+	opLoop.AddReceiver(&recorder)
+	opLoop.AddReceiver(&broadcaster)
+
+	if *headless {
+		start()
 
-	pv.OnScreenReady = opLoop.Start
-	opLoop.Receiver = &pv
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		<-stop
+	} else {
+		//pv.Debug = true
+		pv.Title = "Simple Painter"
 
-	go func() {
-		http.Handle("/", Lang.HttpHandler(&opLoop, &parser))
-		_ = http.ListenAndServe("localhost:17000", nil)
-	}()
+		// Цикл малює в пам'яті, щоб кадри можна було прочитати, а вікно переносить їх у текстуру драйвера.
+		pv.OnScreenReady = func(screen.Screen) { start() }
+		opLoop.Receiver = &pv
 
-	pv.Main()
+		pv.Main()
+	}
 	opLoop.StopAndWait()
 }
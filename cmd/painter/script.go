@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+	"github.com/roman-mazur/architecture-lab-3/painter/lang"
+)
+
+// runScripts по черзі виконує скрипти з файлів та повертає операції всіх скриптів. Назва "-" означає
+// стандартний ввід. Результати інформаційних команд виводяться в stdout.
+func runScripts(p *Lang.Parser, names []string) ([]Painter.Operation, error) {
+	var res []Painter.Operation
+	for _, name := range names {
+		ops, err := runScript(p, name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		res = append(res, ops...)
+	}
+	return res, nil
+}
+
+func runScript(p *Lang.Parser, name string) ([]Painter.Operation, error) {
+	var in io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+	return p.Execute(in, os.Stdout)
+}
+
+// renderPNG виконує операції на текстурі в пам'яті та записує останній кадр у PNG файл. Якщо операції не
+// завершуються командою update, кадр публікується після них автоматично. Анімації не відтворюються: фігури
+// одразу опиняються в кінцевих точках.
+func renderPNG(ops []Painter.Operation, size image.Point, path string) error {
+	var rec Painter.Recorder
+	loop := Painter.NewLoop(Painter.WithSize(size))
+	loop.Receiver = &rec
+	loop.Start(Painter.SoftScreen{})
+	for _, op := range ops {
+		if a, ok := op.(Painter.AnimateOp); ok {
+			op = a.State
+		}
+		loop.Post(op)
+	}
+	if len(ops) == 0 || ops[len(ops)-1] != Painter.UpdateOp {
+		loop.Post(Painter.UpdateOp)
+	}
+	loop.StopAndWait()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, rec.Frame()); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,162 @@
+package Painter
+
+import (
+	"time"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// DefaultAnimationFPS частота кадрів анімацій, якщо для Loop не задано MaxFPS.
+const DefaultAnimationFPS = 60
+
+// Easing визначає, як швидкість анімації змінюється з часом.
+type Easing int
+
+const (
+	EaseLinear Easing = iota // Рівномірний рух.
+	EaseIn                   // Розгін з місця.
+	EaseOut                  // Гальмування перед зупинкою.
+	EaseInOut                // Розгін на початку та гальмування в кінці.
+)
+
+var easingNames = map[Easing]string{
+	EaseLinear: "linear",
+	EaseIn:     "ease-in",
+	EaseOut:    "ease-out",
+	EaseInOut:  "ease-in-out",
+}
+
+func (e Easing) String() string {
+	return easingNames[e]
+}
+
+// ParseEasing повертає функцію згладжування за її назвою (linear, ease-in, ease-out, ease-in-out).
+func ParseEasing(name string) (Easing, bool) {
+	for e, n := range easingNames {
+		if n == name {
+			return e, true
+		}
+	}
+	return 0, false
+}
+
+// At повертає частку пройденого шляху для частки часу t з діапазону [0, 1].
+func (e Easing) At(t float64) float64 {
+	t = min(max(t, 0), 1)
+	switch e {
+	case EaseIn:
+		return t * t
+	case EaseOut:
+		return 1 - (1-t)*(1-t)
+	case EaseInOut:
+		if t < 0.5 {
+			return 2 * t * t
+		}
+		return 1 - 2*(1-t)*(1-t)
+	default:
+		return t
+	}
+}
+
+// Animation переміщення фігури з ідентифікатором ID з точки From у точку To за час Duration.
+type Animation struct {
+	ID       string
+	From, To RelativePoint
+	Duration time.Duration
+	Easing   Easing
+}
+
+// AnimateOp запускає анімації у Loop. State містить стан малюнку, в якому фігури вже знаходяться в кінцевих точках
+// анімацій. Як і будь-який стан, він з'являється на екрані лише після UpdateOp, і разом з ним починаються анімації:
+// до їх завершення цикл малює фігури в проміжних положеннях, самостійно публікуючи кадри, а останній кадр показує
+// фігури в кінцевих точках. Анімація фігури, яка вже рухається, починається після завершення попередніх. Для фігур
+// з Cancel усі анімації спочатку скасовуються, і фігури одразу опиняються в кінцевих точках зі State. Анімація
+// також скасовується, якщо в стані, показаному UpdateOp, фігури немає або вона стоїть не в кінцевій точці, тобто
+// її змінили інші команди.
+type AnimateOp struct {
+	State      *StatefulOperationList
+	Animations []Animation
+	Cancel     []string
+}
+
+// Do малює кінцевий стан. Loop обробляє AnimateOp окремо.
+func (op AnimateOp) Do(t screen.Texture) bool {
+	return op.State.Do(t)
+}
+
+// runningAnimation анімація з часом початку.
+type runningAnimation struct {
+	Animation
+	start time.Time
+}
+
+// animator зберігає анімації циклу. Використовується лише в горутині циклу.
+type animator struct {
+	// Черги анімацій кожної фігури. Перша анімація в черзі виконується, інші чекають на її завершення.
+	queues map[string][]runningAnimation
+}
+
+func (a *animator) active() bool {
+	return len(a.queues) > 0
+}
+
+func (a *animator) cancel(id string) {
+	delete(a.queues, id)
+}
+
+// prune скасовує анімації фігур, яких немає в стані, або які стоять не в кінцевій точці останньої анімації.
+// Повертає true, якщо якусь анімацію скасовано.
+func (a *animator) prune(state *StatefulOperationList) bool {
+	pruned := false
+	for id, queue := range a.queues {
+		if f := state.Figure(id); f == nil || f.Center != queue[len(queue)-1].To {
+			delete(a.queues, id)
+			pruned = true
+		}
+	}
+	return pruned
+}
+
+// add додає анімацію в кінець черги фігури. Анімація в порожній черзі починається в момент now.
+func (a *animator) add(anim Animation, now time.Time) {
+	if a.queues == nil {
+		a.queues = make(map[string][]runningAnimation)
+	}
+	queue := a.queues[anim.ID]
+	start := now
+	if n := len(queue); n > 0 {
+		start = queue[n-1].start.Add(queue[n-1].Duration)
+	}
+	a.queues[anim.ID] = append(queue, runningAnimation{Animation: anim, start: start})
+}
+
+// apply повертає копію стану, в якій фігури перенесено в положення анімацій у момент now. Завершені анімації
+// видаляються. Якщо активних анімацій немає, повертається сам стан.
+func (a *animator) apply(state *StatefulOperationList, now time.Time) *StatefulOperationList {
+	if !a.active() {
+		return state
+	}
+	res := state.Clone()
+	for id, queue := range a.queues {
+		for len(queue) > 0 && !now.Before(queue[0].start.Add(queue[0].Duration)) {
+			queue = queue[1:]
+		}
+		if len(queue) == 0 {
+			delete(a.queues, id)
+			continue
+		}
+		a.queues[id] = queue
+		f := res.Figure(id)
+		if f == nil {
+			continue
+		}
+
+		cur := queue[0]
+		k := cur.Easing.At(float64(now.Sub(cur.start)) / float64(cur.Duration))
+		f.Center = RelativePoint{
+			X: cur.From.X + (cur.To.X-cur.From.X)*k,
+			Y: cur.From.Y + (cur.To.Y-cur.From.Y)*k,
+		}
+	}
+	return &res
+}
@@ -0,0 +1,187 @@
+package Painter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/shiny/screen"
+)
+
+func TestEasing_At(t *testing.T) {
+	for _, e := range []Easing{EaseLinear, EaseIn, EaseOut, EaseInOut} {
+		assert.Equal(t, 0.0, e.At(0), e.String())
+		assert.Equal(t, 1.0, e.At(1), e.String())
+		assert.Equal(t, 1.0, e.At(2), e.String())
+	}
+	assert.Equal(t, 0.25, EaseIn.At(0.5))
+	assert.Equal(t, 0.75, EaseOut.At(0.5))
+	assert.Equal(t, 0.5, EaseInOut.At(0.5))
+	assert.Equal(t, 0.125, EaseInOut.At(0.25))
+}
+
+func TestAnimator_Chain(t *testing.T) {
+	state := &StatefulOperationList{FigureOperations: []*OperationFigure{{ID: "a", Center: RelativePoint{X: 1, Y: 1}}}}
+	start := time.Now()
+	var a animator
+	a.add(Animation{ID: "a", From: RelativePoint{}, To: RelativePoint{X: 1}, Duration: time.Second}, start)
+	a.add(Animation{ID: "a", From: RelativePoint{X: 1}, To: RelativePoint{X: 1, Y: 1}, Duration: time.Second}, start)
+	a.add(Animation{ID: "gone", Duration: time.Second}, start)
+
+	frame := a.apply(state, start.Add(500*time.Millisecond))
+	assert.Equal(t, RelativePoint{X: 0.5}, frame.Figure("a").Center)
+	assert.Equal(t, RelativePoint{X: 1, Y: 1}, state.Figure("a").Center)
+
+	// Фігури gone немає в стані, тож її анімацію скасовує prune, а анімація a закінчується в центрі фігури.
+	assert.Contains(t, a.queues, "gone")
+	assert.True(t, a.prune(state))
+	assert.NotContains(t, a.queues, "gone")
+	assert.Contains(t, a.queues, "a")
+
+	frame = a.apply(state, start.Add(1500*time.Millisecond))
+	assert.Equal(t, RelativePoint{X: 1, Y: 0.5}, frame.Figure("a").Center)
+
+	frame = a.apply(state, start.Add(2*time.Second))
+	assert.Equal(t, RelativePoint{X: 1, Y: 1}, frame.Figure("a").Center)
+	assert.False(t, a.active())
+
+	a.add(Animation{ID: "a", From: RelativePoint{}, To: RelativePoint{X: 0.5}, Duration: time.Second}, start)
+	assert.True(t, a.prune(state))
+	assert.False(t, a.active())
+}
+
+func TestLoop_Animate(t *testing.T) {
+	frames := make(chan screen.Texture, 100)
+	loop := NewLoop(WithSize(image.Pt(100, 100)), WithMaxFPS(50))
+	loop.Receiver = ReceiverFunc(func(t screen.Texture) { frames <- t })
+	loop.Start(SoftScreen{})
+
+	red := color.RGBA{R: 0xff, A: 0xff}
+	state := &StatefulOperationList{FigureOperations: []*OperationFigure{
+		{ID: "a", Center: RelativePoint{X: 0.9, Y: 0.5}, Color: red, Shape: Circle{R: 0.05}},
+	}}
+	loop.Post(AnimateOp{
+		State:      state,
+		Animations: []Animation{{ID: "a", From: RelativePoint{X: 0.1, Y: 0.5}, To: RelativePoint{X: 0.9, Y: 0.5}, Duration: 300 * time.Millisecond}},
+	})
+	loop.Post(UpdateOp)
+
+	at := func(frame screen.Texture, x int) color.RGBA {
+		return frame.(*SoftTexture).RGBA().RGBAAt(x, 50)
+	}
+	// Перший кадр показує фігуру на початку шляху, а не в кінцевій точці зі стану.
+	first := <-frames
+	assert.NotEqual(t, red, at(first, 90))
+
+	// Цикл сам публікує проміжні кадри, поки анімація не завершиться.
+	var count int
+	var last screen.Texture
+	deadline := time.After(2 * time.Second)
+	for last == nil || at(last, 90) != red {
+		select {
+		case last = <-frames:
+			count++
+		case <-deadline:
+			t.Fatal("animation did not finish")
+		}
+	}
+	loop.StopAndWait()
+	assert.Greater(t, count, 3)
+	assert.NotEqual(t, red, at(last, 10))
+}
+
+func TestLoop_AnimateWithoutUpdate(t *testing.T) {
+	frames := make(chan screen.Texture, 100)
+	loop := NewLoop(WithSize(image.Pt(100, 100)), WithMaxFPS(50))
+	loop.Receiver = ReceiverFunc(func(t screen.Texture) { frames <- t })
+	loop.Start(SoftScreen{})
+	defer loop.StopAndWait()
+
+	red := color.RGBA{R: 0xff, A: 0xff}
+	figure := &OperationFigure{ID: "a", Center: RelativePoint{X: 0.9, Y: 0.5}, Shape: Circle{R: 0.05}}
+	loop.Post(AnimateOp{
+		State:      &StatefulOperationList{FigureOperations: []*OperationFigure{figure}},
+		Animations: []Animation{{ID: "a", From: RelativePoint{X: 0.1, Y: 0.5}, To: RelativePoint{X: 0.9, Y: 0.5}, Duration: time.Second}},
+	})
+	// Анімація не починається без UpdateOp.
+	select {
+	case <-frames:
+		t.Fatal("animation started without update")
+	case <-time.After(100 * time.Millisecond):
+	}
+	loop.Post(UpdateOp)
+	<-frames
+
+	// Стан без UpdateOp не потрапляє в кадри анімації.
+	loop.Post(&StatefulOperationList{BgOperation: OperationFill{Color: red}, FigureOperations: []*OperationFigure{figure}})
+	deadline := time.After(200 * time.Millisecond)
+	for count := 0; ; count++ {
+		select {
+		case frame := <-frames:
+			assert.NotEqual(t, red, frame.(*SoftTexture).RGBA().RGBAAt(0, 0))
+			continue
+		case <-deadline:
+			assert.Greater(t, count, 3)
+		}
+		break
+	}
+
+	loop.Post(UpdateOp)
+	for frame := range frames {
+		if frame.(*SoftTexture).RGBA().RGBAAt(0, 0) == red {
+			break
+		}
+	}
+}
+
+func TestLoop_AnimateReusedID(t *testing.T) {
+	frames := make(chan screen.Texture, 100)
+	loop := NewLoop(WithSize(image.Pt(100, 100)), WithMaxFPS(50))
+	loop.Receiver = ReceiverFunc(func(t screen.Texture) { frames <- t })
+	loop.Start(SoftScreen{})
+	defer loop.StopAndWait()
+
+	red := color.RGBA{R: 0xff, A: 0xff}
+	loop.Post(AnimateOp{
+		State: &StatefulOperationList{FigureOperations: []*OperationFigure{
+			{ID: "f1", Center: RelativePoint{X: 0.9, Y: 0.5}, Shape: Circle{R: 0.05}},
+		}},
+		Animations: []Animation{{ID: "f1", From: RelativePoint{X: 0.1, Y: 0.5}, To: RelativePoint{X: 0.9, Y: 0.5}, Duration: time.Second}},
+	})
+	loop.Post(UpdateOp)
+	<-frames
+
+	// Після reset нова фігура отримує той самий ідентифікатор, але не стоїть у кінцевій точці анімації.
+	loop.Post(&StatefulOperationList{FigureOperations: []*OperationFigure{
+		{ID: "f1", Center: RelativePoint{X: 0.5, Y: 0.2}, Color: red, Shape: Circle{R: 0.05}},
+	}})
+	loop.Post(UpdateOp)
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case frame := <-frames:
+			img := frame.(*SoftTexture).RGBA()
+			if img.RGBAAt(50, 20) != red {
+				continue
+			}
+			for x := 0; x < 100; x++ {
+				assert.NotEqual(t, red, img.RGBAAt(x, 50))
+			}
+		case <-deadline:
+			t.Fatal("new figure is not shown")
+		}
+		break
+	}
+	// Анімацію скасовано, тож цикл більше не публікує кадрів.
+	time.Sleep(50 * time.Millisecond)
+	for len(frames) > 0 {
+		<-frames
+	}
+	select {
+	case <-frames:
+		t.Fatal("animation is still running")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
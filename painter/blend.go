@@ -0,0 +1,80 @@
+package Painter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// BlendMode визначає, як колір операції поєднується з пікселями, які вже є на текстурі. Кольори обробляються
+// у форматі з попередньо помноженою прозорістю (premultiplied alpha).
+type BlendMode int
+
+const (
+	BlendOver     BlendMode = iota // Накладання з урахуванням прозорості.
+	BlendSrc                       // Заміна пікселів без змішування.
+	BlendMultiply                  // Множення кольорів, результат темніший за обидва.
+	BlendScreen                    // Інвертоване множення, результат світліший за обидва.
+)
+
+var blendNames = map[BlendMode]string{
+	BlendOver:     "over",
+	BlendSrc:      "src",
+	BlendMultiply: "multiply",
+	BlendScreen:   "screen",
+}
+
+func (m BlendMode) String() string {
+	return blendNames[m]
+}
+
+// ParseBlendMode повертає режим за його назвою (over, src, multiply, screen).
+func ParseBlendMode(name string) (BlendMode, bool) {
+	for m, n := range blendNames {
+		if n == name {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
+// fillRect зафарбовує прямокутник кольором c у режимі mode. Режими multiply та screen потребують читання пікселів,
+// тож для текстур, які не зберігаються в пам'яті, вони замінюються на over.
+func fillRect(t screen.Texture, r image.Rectangle, c color.Color, mode BlendMode) {
+	st, soft := t.(*SoftTexture)
+	switch {
+	case mode == BlendSrc:
+		t.Fill(r, c, draw.Src)
+	case mode == BlendOver || !soft:
+		t.Fill(r, c, draw.Over)
+	default:
+		blendRect(st.rgba, r.Intersect(st.rgba.Rect), c, mode)
+	}
+}
+
+func blendRect(dst *image.RGBA, r image.Rectangle, c color.Color, mode BlendMode) {
+	const m = 0xffff
+	sr, sg, sb, sa32 := c.RGBA()
+	src, sa := [3]uint64{uint64(sr), uint64(sg), uint64(sb)}, uint64(sa32)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := dst.Pix[dst.PixOffset(r.Min.X, y):]
+		for x := 0; x < r.Dx(); x++ {
+			px := row[4*x : 4*x+4]
+			da := uint64(px[3]) * 0x101
+			for i, sc := range src {
+				dc := uint64(px[i]) * 0x101
+				var res uint64
+				switch mode {
+				case BlendMultiply:
+					res = (sc*dc + sc*(m-da) + dc*(m-sa)) / m
+				case BlendScreen:
+					res = sc + dc - sc*dc/m
+				}
+				px[i] = uint8(min(res, m) >> 8)
+			}
+			px[3] = uint8((sa + da - sa*da/m) >> 8)
+		}
+	}
+}
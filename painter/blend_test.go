@@ -0,0 +1,35 @@
+package Painter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFillRect_BlendModes(t *testing.T) {
+	type testCase struct {
+		mode BlendMode
+		bg   color.Color
+		src  color.Color
+		want color.RGBA
+	}
+	halfRed := color.NRGBA{R: 0xff, A: 0x80}
+	testTable := []testCase{
+		{mode: BlendSrc, bg: color.White, src: halfRed, want: color.RGBA{R: 0x80, A: 0x80}},
+		{mode: BlendOver, bg: color.White, src: halfRed, want: color.RGBA{R: 0xff, G: 0x7f, B: 0x7f, A: 0xff}},
+		{mode: BlendMultiply, bg: color.RGBA{R: 0x80, G: 0xff, B: 0xff, A: 0xff}, src: color.RGBA{R: 0xff, G: 0x80, A: 0xff},
+			want: color.RGBA{R: 0x80, G: 0x80, A: 0xff}},
+		{mode: BlendScreen, bg: color.RGBA{R: 0x80, A: 0xff}, src: color.RGBA{R: 0x80, B: 0xff, A: 0xff},
+			want: color.RGBA{R: 0xc0, B: 0xff, A: 0xff}},
+	}
+
+	for _, test := range testTable {
+		tx := NewSoftTexture(image.Pt(4, 4))
+		tx.Fill(tx.Bounds(), test.bg, 0)
+		fillRect(tx, image.Rect(1, 1, 3, 3), test.src, test.mode)
+		assert.Equal(t, test.want, tx.RGBA().At(1, 1), test.mode.String())
+		assert.Equal(t, color.RGBAModel.Convert(test.bg), tx.RGBA().At(0, 0), test.mode.String())
+	}
+}
@@ -0,0 +1,58 @@
+package Painter
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"golang.org/x/exp/shiny/screen"
+	xdraw "golang.org/x/image/draw"
+)
+
+// OperationImage малює растрове зображення. Position задає лівий верхній кут зображення, а Size його ширину та
+// висоту відносно розміру текстури. Якщо Size нульовий, зображення малюється в натуральному розмірі в пікселях.
+// Name зберігається лише для опису стану, пікселі беруться з Image.
+type OperationImage struct {
+	Name     string
+	Image    image.Image
+	Position RelativePoint
+	Size     RelativePoint
+}
+
+func (op OperationImage) Do(t screen.Texture) bool {
+	if op.Image == nil {
+		return false
+	}
+	dr := op.rect(t.Size())
+	bounds := dr.Intersect(t.Bounds())
+	if bounds.Empty() {
+		return false
+	}
+
+	buf := &softBuffer{rgba: image.NewRGBA(image.Rectangle{Max: bounds.Size()})}
+	if st, soft := t.(*SoftTexture); soft {
+		// Прозорі пікселі зображення накладаються на те, що вже намальовано.
+		draw.Draw(buf.rgba, buf.rgba.Rect, st.rgba, bounds.Min, draw.Src)
+	}
+	// Зображення масштабується в координатах буфера, тож частина, яка виходить за межі текстури, відкидається.
+	xdraw.ApproxBiLinear.Scale(buf.rgba, dr.Sub(bounds.Min), op.Image, op.Image.Bounds(), draw.Over, nil)
+	t.Upload(bounds.Min, buf, buf.Bounds())
+	return false
+}
+
+// rect повертає прямокутник у пікселях, який займає зображення на текстурі розміру size.
+func (op OperationImage) rect(size image.Point) image.Rectangle {
+	origin := op.Position.ToAbs(size)
+	imgSize := op.Image.Bounds().Size()
+	if op.Size.X > 0 && op.Size.Y > 0 {
+		imgSize = image.Pt(
+			int(math.Round(op.Size.X*float64(size.X))),
+			int(math.Round(op.Size.Y*float64(size.Y))),
+		)
+	}
+	return image.Rectangle{Min: origin, Max: origin.Add(imgSize)}
+}
+
+func (op OperationImage) SetState(sol *StatefulOperationList) {
+	sol.ImageOperations = append(sol.ImageOperations, op)
+}
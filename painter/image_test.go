@@ -0,0 +1,33 @@
+package Painter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperationImage_Do(t *testing.T) {
+	red := color.RGBA{R: 0xff, A: 0xff}
+	white := color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	// Ліва половина зображення червона, права прозора.
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 2; x++ {
+		img.Set(x, 0, red)
+		img.Set(x, 1, red)
+	}
+
+	tx := NewSoftTexture(image.Pt(20, 20))
+	OperationFill{Color: white}.Do(tx)
+	OperationImage{Image: img, Position: RelativePoint{X: 0.1, Y: 0.1}}.Do(tx)
+	assert.Equal(t, red, tx.RGBA().RGBAAt(2, 2))
+	assert.Equal(t, white, tx.RGBA().RGBAAt(4, 2))
+	assert.Equal(t, white, tx.RGBA().RGBAAt(2, 4))
+
+	// Масштабоване зображення, яке частково виходить за межі текстури.
+	OperationImage{Image: img, Position: RelativePoint{X: 0.5, Y: 0.5}, Size: RelativePoint{X: 1, Y: 0.5}}.Do(tx)
+	assert.Equal(t, red, tx.RGBA().RGBAAt(12, 15))
+	assert.Equal(t, white, tx.RGBA().RGBAAt(9, 15))
+	assert.Equal(t, white, tx.RGBA().RGBAAt(12, 9))
+}
@@ -0,0 +1,79 @@
+package Lang
+
+import (
+	"encoding/hex"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
+
+// parseColor розбирає колір у одному з форматів: назва кольору CSS (red, steelblue), #RRGGBB, #RRGGBBAA,
+// rgb(r, g, b) або rgba(r, g, b, a), де r, g, b в діапазоні [0, 255], а a в діапазоні [0, 1].
+func parseColor(s string) (color.Color, error) {
+	s = strings.ToLower(s)
+
+	if c, ok := colornames.Map[s]; ok {
+		return c, nil
+	}
+	if hexStr, ok := strings.CutPrefix(s, "#"); ok {
+		if len(hexStr) != 6 && len(hexStr) != 8 {
+			return nil, fmt.Errorf("invalid color")
+		}
+		b, err := hex.DecodeString(hexStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid color")
+		}
+		c := color.NRGBA{R: b[0], G: b[1], B: b[2], A: 0xff}
+		if len(b) == 4 {
+			c.A = b[3]
+		}
+		return c, nil
+	}
+	if args, ok := functionArgs(s, "rgb"); ok && len(args) == 3 {
+		return parseRGBA(args)
+	}
+	if args, ok := functionArgs(s, "rgba"); ok && len(args) == 4 {
+		return parseRGBA(args)
+	}
+	return nil, fmt.Errorf("invalid color")
+}
+
+// functionArgs повертає аргументи запису виду name(a, b, c).
+func functionArgs(s, name string) ([]string, bool) {
+	s, ok := strings.CutPrefix(s, name+"(")
+	if !ok {
+		return nil, false
+	}
+	s, ok = strings.CutSuffix(s, ")")
+	if !ok {
+		return nil, false
+	}
+	args := strings.Split(s, ",")
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+	return args, true
+}
+
+func parseRGBA(args []string) (color.Color, error) {
+	var ch [3]uint8
+	for i := range ch {
+		v, err := strconv.ParseUint(args[i], 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid color")
+		}
+		ch[i] = uint8(v)
+	}
+	c := color.NRGBA{R: ch[0], G: ch[1], B: ch[2], A: 0xff}
+	if len(args) == 4 {
+		a, err := strconv.ParseFloat(args[3], 64)
+		if err != nil || a < 0 || a > 1 {
+			return nil, fmt.Errorf("invalid color")
+		}
+		c.A = uint8(a*0xff + 0.5)
+	}
+	return c, nil
+}
@@ -0,0 +1,52 @@
+package Lang
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+
+	Painter "github.com/roman-mazur/architecture-lab-3/painter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseColor(t *testing.T) {
+	testTable := []struct {
+		in  string
+		out color.Color
+	}{
+		{in: "red", out: color.RGBA{R: 0xff, A: 0xff}},
+		{in: "SteelBlue", out: color.RGBA{R: 0x46, G: 0x82, B: 0xb4, A: 0xff}},
+		{in: "#102030", out: color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0xff}},
+		{in: "#10203080", out: color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0x80}},
+		{in: "rgb(1, 2, 3)", out: color.NRGBA{R: 1, G: 2, B: 3, A: 0xff}},
+		{in: "rgba(1,2,3,0.5)", out: color.NRGBA{R: 1, G: 2, B: 3, A: 0x80}},
+	}
+	for _, test := range testTable {
+		c, err := parseColor(test.in)
+		assert.Nil(t, err, test.in)
+		assert.Equal(t, test.out, c, test.in)
+	}
+
+	for _, in := range []string{"nocolor", "#12345", "#zzzzzz", "rgb(1,2)", "rgb(256,0,0)", "rgba(1,2,3,2)"} {
+		_, err := parseColor(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestParser_Colors(t *testing.T) {
+	p := &Parser{}
+	ops, err := p.Parse(strings.NewReader("fill #ffffff\nbgrect 0 0 0.5 0.5 rgba(255, 0, 0, 0.5)\nfigure 0.5 0.5 navy"))
+	assert.Nil(t, err)
+	assert.Len(t, ops, 3)
+
+	st := ops[2].(*Painter.StatefulOperationList)
+	assert.Equal(t, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, st.BgOperation.(Painter.OperationFill).Color)
+	assert.Equal(t, color.NRGBA{R: 0xff, A: 0x80}, st.BgRectOperations[0].Color)
+	assert.Equal(t, color.RGBA{B: 0x80, A: 0xff}, st.FigureOperations[0].Color)
+
+	_, err = p.Parse(strings.NewReader("figure 0.5 0.5 rgb(1, 2)"))
+	var errs ParseErrors
+	assert.ErrorAs(t, err, &errs)
+	assert.Equal(t, "rgb(1, 2)", errs[0].Token)
+	assert.Equal(t, 16, errs[0].Column)
+}
@@ -1,15 +1,29 @@
 package Lang
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	Painter "github.com/roman-mazur/architecture-lab-3/painter"
+	"image/jpeg"
+	"image/png"
 	"io"
 	"log"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"strconv"
 	"strings"
 )
 
 // HttpHandler конструює обробник HTTP запитів, який дані з запиту віддає у Parser, а потім відправляє отриманий список
-// операцій у Painter.Loop.
+// операцій у Painter.Loop. Запити з Content-Type application/json обробляються через Parser.ExecuteJSON, і помилки
+// в них повертаються також у JSON. Результати інформаційних команд (list) повертаються в тілі відповіді: текстом
+// або як {"output": "..."} для JSON запитів. Якщо черга циклу заповнена, відповідь має статус 429, а якщо цикл
+// зупиняється, 503. В обох випадках запит не має жодного ефекту: операції не потрапляють у цикл, а стан Parser
+// залишається таким, як до запиту, тож запит можна безпечно повторити. Скрипт, операцій якого більше, ніж
+// місткість черги, так само нічого не змінює, але відхиляється зі статусом 413, бо повторювати його марно.
 func HttpHandler(loop *Painter.Loop, p *Parser) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		var in io.Reader = r.Body
@@ -17,15 +31,202 @@ func HttpHandler(loop *Painter.Loop, p *Parser) http.Handler {
 			in = strings.NewReader(r.URL.Query().Get("Cmd"))
 		}
 
-		cmds, err := p.Parse(in)
+		var (
+			out     bytes.Buffer
+			err     error
+			postErr error
+		)
+		post := func(ops ...Painter.Operation) error {
+			postErr = loop.TryPost(ops...)
+			return postErr
+		}
+		isJSON := isJSONRequest(r)
+		if isJSON {
+			_, err = p.executeJSON(in, &out, post)
+		} else {
+			_, err = p.execute(in, &out, post)
+		}
+		if postErr != nil {
+			log.Printf("Cannot post operations: %s", postErr)
+			writePostError(rw, isJSON, postErr)
+			return
+		}
 		if err != nil {
 			log.Printf("Bad script: %s", err)
-			rw.WriteHeader(http.StatusBadRequest)
+			if isJSON {
+				writeJSONError(rw, http.StatusBadRequest, err)
+			} else {
+				rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				rw.WriteHeader(http.StatusBadRequest)
+				_, _ = io.WriteString(rw, err.Error()+"\n")
+			}
+			return
+		}
+		if out.Len() == 0 {
+			rw.WriteHeader(http.StatusOK)
+		} else if isJSON {
+			rw.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(rw).Encode(map[string]string{"output": out.String()})
+		} else {
+			rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = out.WriteTo(rw)
+		}
+	})
+}
+
+// UndoHandler конструює обробник POST запитів, який скасовує останні зміни стану (див. Parser.Undo) та оновлює
+// малюнок. Кількість змін задається параметром запиту n, за замовчуванням одна. Якщо скасовувати нічого,
+// відповідь має статус 409. Як і в HttpHandler, відповіді 429 та 503 означають, що історія не змінилася.
+func UndoHandler(loop *Painter.Loop, p *Parser) http.Handler {
+	return historyHandler(loop, p.undoAndPost)
+}
+
+// RedoHandler працює так само, як UndoHandler, але повторює скасовані зміни (див. Parser.Redo).
+func RedoHandler(loop *Painter.Loop, p *Parser) http.Handler {
+	return historyHandler(loop, p.redoAndPost)
+}
+
+func historyHandler(loop *Painter.Loop, travel func(n int, post postFunc) (Painter.Operation, bool, error)) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		for _, cmd := range cmds {
-			loop.Post(cmd)
+		n := 1
+		if v := r.URL.Query().Get("n"); v != "" {
+			var err error
+			if n, err = strconv.Atoi(v); err != nil || n < 1 {
+				http.Error(rw, "n must be a positive integer", http.StatusBadRequest)
+				return
+			}
+		}
+		_, ok, err := travel(n, func(ops ...Painter.Operation) error {
+			return loop.TryPost(append(ops, Painter.UpdateOp)...)
+		})
+		if !ok {
+			http.Error(rw, "no changes in history", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			writePostError(rw, false, err)
+			return
 		}
 		rw.WriteHeader(http.StatusOK)
 	})
 }
+
+// writePostError відповідає на помилку відправлення операцій у цикл: 429, якщо черга заповнена, 413, якщо операції
+// не вмістяться в чергу навіть порожню, та 503 в інших випадках.
+func writePostError(rw http.ResponseWriter, isJSON bool, err error) {
+	status := http.StatusServiceUnavailable
+	switch {
+	case errors.Is(err, Painter.ErrQueueFull):
+		status = http.StatusTooManyRequests
+		rw.Header().Set("Retry-After", "1")
+	case errors.Is(err, Painter.ErrTooManyOperations):
+		status = http.StatusRequestEntityTooLarge
+	}
+	if isJSON {
+		writeJSONError(rw, status, err)
+	} else {
+		http.Error(rw, err.Error(), status)
+	}
+}
+
+func isJSONRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// writeJSONError відповідає об'єктом {"errors": [...]}. Елементи ParseErrors серіалізуються з усіма полями, інші
+// помилки містять лише "message".
+func writeJSONError(rw http.ResponseWriter, status int, err error) {
+	var body struct {
+		Errors []any `json:"errors"`
+	}
+	var errs ParseErrors
+	if errors.As(err, &errs) {
+		for _, e := range errs {
+			body.Errors = append(body.Errors, e)
+		}
+	} else {
+		body.Errors = append(body.Errors, map[string]string{"message": err.Error()})
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	_ = json.NewEncoder(rw).Encode(body)
+}
+
+// StatsHandler конструює обробник HTTP запитів, який повертає лічильники циклу (див. Painter.LoopStats) у JSON.
+func StatsHandler(loop *Painter.Loop) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(rw).Encode(loop.Stats())
+	})
+}
+
+// SnapshotHandler конструює обробник HTTP запитів, який повертає останній опублікований кадр у форматі PNG.
+func SnapshotHandler(rec *Painter.Recorder) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		frame := rec.Frame()
+		if frame == nil {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rw.Header().Set("Content-Type", "image/png")
+		if err := png.Encode(rw, frame); err != nil {
+			log.Printf("Failed to encode snapshot: %s", err)
+		}
+	})
+}
+
+// StreamHandler конструює обробник HTTP запитів, який транслює клієнту кожен опублікований кадр як MJPEG потік
+// (multipart/x-mixed-replace), що його браузер показує як анімоване зображення.
+func StreamHandler(b *Painter.Broadcaster) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		frames, cancel := b.Subscribe()
+		defer cancel()
+
+		mw := multipart.NewWriter(rw)
+		rw.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+mw.Boundary())
+		rw.Header().Set("Cache-Control", "no-cache")
+		rw.WriteHeader(http.StatusOK)
+		rc := http.NewResponseController(rw)
+		if err := rc.Flush(); err != nil {
+			log.Printf("Stream closed: %s", err)
+			return
+		}
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case frame := <-frames:
+				part, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"image/jpeg"}})
+				if err == nil {
+					err = jpeg.Encode(part, frame, nil)
+				}
+				if err == nil {
+					err = rc.Flush()
+				}
+				if err != nil {
+					log.Printf("Stream closed: %s", err)
+					return
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,240 @@
+package Lang
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	Painter "github.com/roman-mazur/architecture-lab-3/painter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotHandler(t *testing.T) {
+	rec := &Painter.Recorder{}
+	handler := SnapshotHandler(rec)
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/snapshot", nil))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+
+	tx := Painter.NewSoftTexture(image.Pt(4, 4))
+	Painter.OperationFill{Color: color.White}.Do(tx)
+	rec.Update(tx)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/snapshot", nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "image/png", rw.Header().Get("Content-Type"))
+
+	img, err := png.Decode(rw.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, image.Rect(0, 0, 4, 4), img.Bounds())
+	r, g, b, _ := img.At(1, 1).RGBA()
+	assert.Equal(t, [3]uint32{0xffff, 0xffff, 0xffff}, [3]uint32{r, g, b})
+}
+
+func TestStreamHandler(t *testing.T) {
+	b := &Painter.Broadcaster{}
+	srv := httptest.NewServer(StreamHandler(b))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	assert.Nil(t, err)
+	assert.Equal(t, "multipart/x-mixed-replace", mediaType)
+
+	tx := Painter.NewSoftTexture(image.Pt(8, 8))
+	Painter.OperationFill{Color: color.White}.Do(tx)
+	b.Update(tx)
+
+	part, err := multipart.NewReader(resp.Body, params["boundary"]).NextPart()
+	assert.Nil(t, err)
+	assert.Equal(t, "image/jpeg", part.Header.Get("Content-Type"))
+	img, err := jpeg.Decode(part)
+	assert.Nil(t, err)
+	assert.Equal(t, image.Rect(0, 0, 8, 8), img.Bounds())
+}
+
+func TestHttpHandler_BadScript(t *testing.T) {
+	handler := HttpHandler(&Painter.Loop{}, &Parser{})
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("white\nfigure 2 0")))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "line 2, column 8: figure: \"2\": value is not in [-1,1] range\n", rw.Body.String())
+}
+
+func TestHttpHandler_JSONErrors(t *testing.T) {
+	handler := HttpHandler(&Painter.Loop{}, &Parser{})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`[{"op": "figure", "x": 0.5, "y": "abc"}]`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"errors": [{"line": 1, "column": 0, "token": "abc", "command": "figure", "message": "invalid number"}]}`,
+		rw.Body.String())
+}
+
+func TestHttpHandler_Output(t *testing.T) {
+	handler := HttpHandler(&Painter.Loop{}, &Parser{})
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("list")))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Empty(t, rw.Body.String())
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`[{"op": "figure", "id": "a", "x": 0.5, "y": 0.5}]`))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`[{"op": "list"}]`))
+	req.Header.Set("Content-Type", "application/json")
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+	assert.JSONEq(t, `{"output": "a 0.5 0.5\n"}`, rw.Body.String())
+}
+
+func TestUndoHandler(t *testing.T) {
+	p := &Parser{}
+	loop := &Painter.Loop{}
+	undo, redo := UndoHandler(loop, p), RedoHandler(loop, p)
+
+	rw := httptest.NewRecorder()
+	undo.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/undo", nil))
+	assert.Equal(t, http.StatusConflict, rw.Code)
+
+	_, err := p.Parse(strings.NewReader("figure a 0.5 0.5\nreset"))
+	assert.Nil(t, err)
+
+	rw = httptest.NewRecorder()
+	undo.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/undo?n=x", nil))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+
+	rw = httptest.NewRecorder()
+	undo.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/undo", nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.NotNil(t, p.state.Figure("a"))
+
+	rw = httptest.NewRecorder()
+	redo.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/redo", nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Nil(t, p.state.Figure("a"))
+
+	rw = httptest.NewRecorder()
+	redo.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/redo", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rw.Code)
+}
+
+func TestHttpHandler_Concurrent(t *testing.T) {
+	var (
+		p   Parser
+		rec Painter.Recorder
+	)
+	loop := Painter.NewLoop(Painter.WithSize(image.Pt(64, 64)))
+	loop.Receiver = &rec
+	loop.Start(Painter.SoftScreen{})
+
+	mux := http.NewServeMux()
+	mux.Handle("/", HttpHandler(loop, &p))
+	mux.Handle("/undo", UndoHandler(loop, &p))
+	mux.Handle("/snapshot", SnapshotHandler(&rec))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				script := fmt.Sprintf("figure 0.%d 0.5\nmove 0.01 0.01\nbgrect 0 0 0.1 0.1\nupdate", i)
+				resp, err := http.Post(srv.URL, "text/plain", strings.NewReader(script))
+				if assert.Nil(t, err) {
+					assert.Equal(t, http.StatusOK, resp.StatusCode)
+					resp.Body.Close()
+				}
+				if resp, err := http.Post(srv.URL+"/undo", "", nil); err == nil {
+					resp.Body.Close()
+				}
+				if resp, err := http.Get(srv.URL + "/snapshot"); err == nil {
+					resp.Body.Close()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	loop.StopAndWait()
+	assert.NotNil(t, rec.Frame())
+}
+
+func TestHttpHandler_Saturated(t *testing.T) {
+	loop := Painter.NewLoop(Painter.WithQueueCapacity(2))
+	p := &Parser{}
+	handler := HttpHandler(loop, p)
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("figure a 0.1 0.1\nupdate")))
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("figure b 0.5 0.5\nupdate")))
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "1", rw.Header().Get("Retry-After"))
+
+	rw = httptest.NewRecorder()
+	UndoHandler(loop, p).ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/undo", nil))
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+
+	// Скрипт, який не вміститься навіть у порожню чергу, не варто повторювати.
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("figure b 0.5 0.5\nupdate\nupdate")))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rw.Code)
+	assert.Empty(t, rw.Header().Get("Retry-After"))
+
+	// Відхилені запити не змінюють ні стан, ні історію.
+	var out strings.Builder
+	_, err := p.Execute(strings.NewReader("list"), &out)
+	assert.Nil(t, err)
+	assert.Equal(t, "a 0.1 0.1\n", out.String())
+	_, ok := p.Undo(1)
+	assert.True(t, ok)
+	_, ok = p.Undo(1)
+	assert.False(t, ok)
+
+	loop.Start(Painter.SoftScreen{})
+	loop.StopAndWait()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`[{"op": "update"}]`))
+	req.Header.Set("Content-Type", "application/json")
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+	assert.JSONEq(t, `{"errors": [{"message": "loop is stopped"}]}`, rw.Body.String())
+}
+
+func TestStatsHandler(t *testing.T) {
+	loop := Painter.NewLoop()
+	loop.Post(&Painter.StatefulOperationList{})
+	loop.Post(&Painter.StatefulOperationList{})
+	loop.Start(Painter.SoftScreen{})
+	loop.StopAndWait()
+
+	rw := httptest.NewRecorder()
+	StatsHandler(loop).ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.JSONEq(t, `{"executed": 2, "coalesced": 1, "published": 0, "dropped": 0}`, rw.Body.String())
+}
@@ -0,0 +1,87 @@
+package Lang
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// MaxImageSize найбільший розмір файлу зображення, який можна завантажити через ImageUploadHandler.
+const MaxImageSize = 16 << 20
+
+// ImageRegistry зберігає зображення за назвами, щоб їх можна було малювати командою image. Безпечний для
+// одночасного використання.
+type ImageRegistry struct {
+	mu     sync.RWMutex
+	images map[string]image.Image
+}
+
+// Add реєструє зображення під вказаною назвою, замінюючи попереднє. Уже намальовані операції продовжують
+// використовувати старе зображення.
+func (r *ImageRegistry) Add(name string, img image.Image) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.images == nil {
+		r.images = make(map[string]image.Image)
+	}
+	r.images[name] = img
+}
+
+// Image повертає зображення з вказаною назвою.
+func (r *ImageRegistry) Image(name string) (image.Image, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	img, ok := r.images[name]
+	return img, ok
+}
+
+// ImageUploadHandler конструює обробник HTTP запитів, який реєструє зображення PNG чи JPEG з тіла POST запиту.
+// Назва зображення береться з параметра шляху {name}, наприклад при реєстрації обробника як "/images/{name}".
+// Зображення, сторони яких більші за MaxCanvasSize, відхиляються ще до декодування пікселів.
+func ImageUploadHandler(reg *ImageRegistry) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		name := r.PathValue("name")
+		if !validID(name) {
+			http.Error(rw, fmt.Sprintf("invalid image name %q", name), http.StatusBadRequest)
+			return
+		}
+		data, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, MaxImageSize))
+		if err != nil {
+			http.Error(rw, "cannot read image: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Невеликий файл може оголосити величезний розмір, тож пам'ять під пікселі виділяється лише після перевірки.
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err == nil && (cfg.Width > MaxCanvasSize || cfg.Height > MaxCanvasSize) {
+			err = fmt.Errorf("image size %dx%d exceeds %d pixels", cfg.Width, cfg.Height, MaxCanvasSize)
+		}
+		var (
+			img    image.Image
+			format string
+		)
+		if err == nil {
+			img, format, err = image.Decode(bytes.NewReader(data))
+		}
+		if err != nil {
+			log.Printf("Bad image %q: %s", name, err)
+			http.Error(rw, "cannot decode image: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		reg.Add(name, img)
+		log.Printf("Registered %s image %q", format, name)
+		rw.WriteHeader(http.StatusCreated)
+	})
+}
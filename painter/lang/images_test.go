@@ -0,0 +1,83 @@
+package Lang
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	Painter "github.com/roman-mazur/architecture-lab-3/painter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageUploadHandler(t *testing.T) {
+	reg := &ImageRegistry{}
+	mux := http.NewServeMux()
+	mux.Handle("/images/{name}", ImageUploadHandler(reg))
+
+	logo := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	logo.Set(1, 1, color.RGBA{R: 0xff, A: 0xff})
+	var body bytes.Buffer
+	assert.Nil(t, png.Encode(&body, logo))
+
+	rw := httptest.NewRecorder()
+	mux.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/images/logo", &body))
+	assert.Equal(t, http.StatusCreated, rw.Code)
+	img, ok := reg.Image("logo")
+	if assert.True(t, ok) {
+		assert.Equal(t, logo.Bounds(), img.Bounds())
+	}
+
+	rw = httptest.NewRecorder()
+	mux.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/images/bad", strings.NewReader("not an image")))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	_, ok = reg.Image("bad")
+	assert.False(t, ok)
+
+	body.Reset()
+	assert.Nil(t, png.Encode(&body, image.NewGray(image.Rect(0, 0, MaxCanvasSize+1, 1))))
+	rw = httptest.NewRecorder()
+	mux.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/images/huge", &body))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Contains(t, rw.Body.String(), "exceeds")
+	_, ok = reg.Image("huge")
+	assert.False(t, ok)
+
+	rw = httptest.NewRecorder()
+	mux.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/images/1logo", strings.NewReader("")))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+
+	rw = httptest.NewRecorder()
+	mux.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/images/logo", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rw.Code)
+}
+
+func TestParser_Image(t *testing.T) {
+	reg := &ImageRegistry{}
+	logo := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	reg.Add("logo", logo)
+	p := &Parser{Images: reg}
+
+	ops, err := p.Parse(strings.NewReader("image logo 0.1 0.2\nimage logo 0.5 0.5 0.25 0.1"))
+	assert.Nil(t, err)
+	st := ops[len(ops)-1].(*Painter.StatefulOperationList)
+	if assert.Len(t, st.ImageOperations, 2) {
+		assert.Equal(t, "logo", st.ImageOperations[0].Name)
+		assert.Same(t, logo, st.ImageOperations[0].Image)
+		assert.Equal(t, Painter.RelativePoint{X: 0.1, Y: 0.2}, st.ImageOperations[0].Position)
+		assert.Equal(t, Painter.RelativePoint{}, st.ImageOperations[0].Size)
+		assert.Equal(t, Painter.RelativePoint{X: 0.25, Y: 0.1}, st.ImageOperations[1].Size)
+	}
+
+	_, err = p.Parse(strings.NewReader("image icon 0.1 0.2\nimage logo 0.1 0.2 0.3\nimage logo 0.1 0.2 0.3 0"))
+	var errs ParseErrors
+	if assert.ErrorAs(t, err, &errs) && assert.Len(t, errs, 3) {
+		assert.Equal(t, "icon", errs[0].Token)
+		assert.IsType(t, countError{}, errs[1].Err)
+		assert.Equal(t, "0", errs[2].Token)
+	}
+}
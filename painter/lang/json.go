@@ -0,0 +1,143 @@
+package Lang
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/roman-mazur/architecture-lab-3/painter"
+	"io"
+	"sort"
+)
+
+// jsonParams задає назви аргументів команд у JSON форматі. Назви перелічені в тому порядку, в якому аргументи
+// записуються у текстовому скрипті.
+var jsonParams = map[string][]string{
+	"fill":     {"color"},
+	"bgrect":   {"x1", "y1", "x2", "y2", "color"},
+	"figure":   {"id", "x", "y", "size", "color"},
+	"circle":   {"id", "x", "y", "r", "color"},
+	"ellipse":  {"id", "x", "y", "rx", "ry", "color"},
+	"line":     {"id", "x1", "y1", "x2", "y2", "width", "color"},
+	"triangle": {"id", "x1", "y1", "x2", "y2", "x3", "y3", "color"},
+	"rmrect":   {"index"},
+	"moverect": {"from", "to"},
+	"move":     {"id", "x", "y"},
+	"moveto":   {"id", "x", "y"},
+	"resize":   {"width", "height"},
+	"blend":    {"mode"},
+	"remove":   {"id"},
+	"text":     {"x", "y", "size", "color", "text"},
+	"image":    {"name", "x", "y", "w", "h"},
+	"save":     {"name"},
+	"load":     {"name"},
+}
+
+// ParseJSON обробляє скрипт у JSON форматі: масив об'єктів, кожен з яких описує одну команду, наприклад
+//
+//	[{"op": "white"}, {"op": "figure", "x": 0.5, "y": 0.5}, {"op": "update"}]
+//
+// Аргументи можна задати за назвами (див. jsonParams) або масивом "args" у порядку текстового скрипта. Необов'язкові
+// аргументи можна пропускати. Команди зі змінною кількістю аргументів (polyline, polygon) та animate приймають лише
+// "args". Команди перетворюються на ті самі операції, що й у Parse. У ParseError номер рядка відповідає номеру
+// команди в масиві, починаючи з 1, а позиція не використовується.
+func (p *Parser) ParseJSON(in io.Reader) ([]Painter.Operation, error) {
+	return p.ExecuteJSON(in, io.Discard)
+}
+
+// ExecuteJSON працює так само, як ParseJSON, але результати інформаційних команд записує в out.
+func (p *Parser) ExecuteJSON(in io.Reader, out io.Writer) ([]Painter.Operation, error) {
+	return p.executeJSON(in, out, nil)
+}
+
+func (p *Parser) executeJSON(in io.Reader, out io.Writer, post postFunc) ([]Painter.Operation, error) {
+	var objects []map[string]json.RawMessage
+	if err := json.NewDecoder(in).Decode(&objects); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	var (
+		cmds []commandLine
+		errs ParseErrors
+	)
+	for i, obj := range objects {
+		fields, err := jsonFields(obj)
+		if err != nil {
+			errs = append(errs, &ParseError{Line: i + 1, Command: fields[0].text, Token: fields[0].text, Err: err})
+			continue
+		}
+		cmds = append(cmds, commandLine{line: i + 1, fields: fields})
+	}
+
+	res, err := p.processAll(cmds, errs, out, post)
+	if errors.As(err, &errs) {
+		sort.SliceStable(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+	}
+	return res, err
+}
+
+// jsonFields перетворює JSON об'єкт команди на токени текстового скрипта. Навіть у разі помилки повертається
+// принаймні один токен з назвою команди.
+func jsonFields(obj map[string]json.RawMessage) ([]token, error) {
+	var name string
+	if raw, ok := obj["op"]; !ok {
+		return []token{{}}, fmt.Errorf("missing \"op\" field")
+	} else if err := json.Unmarshal(raw, &name); err != nil {
+		return []token{{}}, fmt.Errorf("\"op\" must be a string")
+	}
+	fields := []token{{text: name}}
+
+	if raw, ok := obj["args"]; ok {
+		if len(obj) > 2 {
+			return fields, fmt.Errorf("\"args\" cannot be combined with named arguments")
+		}
+		var args []json.RawMessage
+		if err := json.Unmarshal(raw, &args); err != nil {
+			return fields, fmt.Errorf("\"args\" must be an array")
+		}
+		for _, arg := range args {
+			tok, err := jsonArgument(arg)
+			if err != nil {
+				return fields, err
+			}
+			fields = append(fields, tok)
+		}
+		return fields, nil
+	}
+
+	used := 1
+	for _, param := range jsonParams[name] {
+		raw, ok := obj[param]
+		if !ok {
+			continue
+		}
+		tok, err := jsonArgument(raw)
+		if err != nil {
+			return fields, fmt.Errorf("%q: %w", param, err)
+		}
+		fields = append(fields, tok)
+		used++
+	}
+	if used != len(obj) {
+		return fields, fmt.Errorf("unexpected fields")
+	}
+	return fields, nil
+}
+
+// jsonArgument перетворює JSON значення аргументу на токен. Рядки JSON вважаються рядками в лапках.
+func jsonArgument(raw json.RawMessage) (token, error) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return token{}, err
+	}
+	switch v := v.(type) {
+	case string:
+		return token{text: v, quoted: true}, nil
+	case json.Number:
+		return token{text: v.String()}, nil
+	default:
+		return token{}, fmt.Errorf("argument must be a string or a number")
+	}
+}
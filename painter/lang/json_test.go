@@ -0,0 +1,63 @@
+package Lang
+
+import (
+	"strings"
+	"testing"
+
+	Painter "github.com/roman-mazur/architecture-lab-3/painter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParser_ParseJSON(t *testing.T) {
+	p := &Parser{}
+	ops, err := p.ParseJSON(strings.NewReader(`[
+		{"op": "white"},
+		{"op": "figure", "x": 0.5, "y": 0.25},
+		{"op": "bgrect", "args": [0.1, "0.1", 0.9, 0.9]},
+		{"op": "update"}
+	]`))
+	assert.Nil(t, err)
+	assert.Len(t, ops, 4)
+	assert.Equal(t, Painter.UpdateOp, ops[3])
+
+	st := ops[2].(*Painter.StatefulOperationList)
+	assert.Len(t, st.FigureOperations, 1)
+	assert.Equal(t, Painter.RelativePoint{X: 0.5, Y: 0.25}, st.FigureOperations[0].Center)
+	assert.Len(t, st.BgRectOperations, 1)
+}
+
+func TestParser_ParseJSON_Errors(t *testing.T) {
+	p := &Parser{}
+
+	_, err := p.ParseJSON(strings.NewReader(`{"op": "white"}`))
+	assert.ErrorContains(t, err, "invalid JSON")
+
+	_, err = p.ParseJSON(strings.NewReader(`[
+		{"op": "figure", "x": 0.5},
+		{"op": "figure", "x": 0.5, "z": 1},
+		{"x": 1},
+		{"op": "move", "x": "a", "y": 0}
+	]`))
+	var errs ParseErrors
+	if !assert.ErrorAs(t, err, &errs) {
+		return
+	}
+	assert.Len(t, errs, 4)
+	assert.Equal(t, []int{1, 2, 3, 4}, []int{errs[0].Line, errs[1].Line, errs[2].Line, errs[3].Line})
+	assert.IsType(t, countError{}, errs[0].Err)
+	assert.ErrorContains(t, errs[1], "unexpected fields")
+	assert.ErrorContains(t, errs[2], "missing \"op\" field")
+	assert.Equal(t, "move", errs[3].Command)
+	assert.Equal(t, "a", errs[3].Token)
+}
+
+func TestParser_ParseJSON_FailedScript(t *testing.T) {
+	p := &Parser{}
+	_, err := p.ParseJSON(strings.NewReader(`[{"op": "figure", "args": ["a", 0.5, 0.5]}, {"x": 1}]`))
+	assert.Error(t, err)
+
+	var out strings.Builder
+	_, err = p.Execute(strings.NewReader("list"), &out)
+	assert.Nil(t, err)
+	assert.Empty(t, out.String())
+}
@@ -2,119 +2,931 @@ package Lang
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/roman-mazur/architecture-lab-3/painter"
+	"image"
 	"image/color"
 	"io"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
+// MaxCanvasSize найбільший розмір сторони полотна, який можна задати командою resize.
+const MaxCanvasSize = 4096
+
+// DefaultHistorySize кількість змін стану, які можна скасувати командою undo, за замовчуванням.
+const DefaultHistorySize = 100
+
 // Parser уміє прочитати дані з вхідного io.Reader та повернути список операцій представлені вхідним скриптом.
+// Parser можна використовувати з кількох горутин одночасно: скрипти виконуються по черзі, а кожна операція зі
+// станом малюнку містить власну незмінну копію стану, тож Loop ніколи не бачить частково застосованих змін.
 type Parser struct {
+	mu sync.Mutex
+
 	// Зберігає стан малюнку у спеціальній операції.
 	state Painter.StatefulOperationList
+	// Режим змішування для нових прямокутників та фігур, встановлюється командою blend.
+	blend Painter.BlendMode
+
+	// Images містить зображення, доступні команді image.
+	Images *ImageRegistry
+	// HistorySize обмежує кількість змін, які можна скасувати. Якщо не додатне, використовується DefaultHistorySize.
+	HistorySize int
+	// ScenesDir каталог, в якому команди save та load зберігають сцени. Якщо порожній, сцени недоступні.
+	ScenesDir string
+
+	undo, redo []snapshot
+	// Сцени, які запише скрипт, що виконується.
+	saves []pendingSave
+}
+
+// snapshot збережений стан парсера для undo та redo.
+type snapshot struct {
+	state Painter.StatefulOperationList
+	blend Painter.BlendMode
 }
 
+// Parse обробляє скрипт рядок за рядком. Якщо хоча б одна команда некоректна, повертається ParseErrors з усіма
+// знайденими помилками, список операцій порожній, а стан парсера, історія та файли сцен залишаються такими, як
+// до виконання скрипта.
 func (p *Parser) Parse(in io.Reader) ([]Painter.Operation, error) {
-	var res []Painter.Operation
+	return p.Execute(in, io.Discard)
+}
+
+// Execute працює так само, як Parse, але результати інформаційних команд (наприклад, list) записує в out.
+func (p *Parser) Execute(in io.Reader, out io.Writer) ([]Painter.Operation, error) {
+	return p.execute(in, out, nil)
+}
+
+// postFunc відправляє операції виконаного скрипта в Loop, поки парсер ще заблоковано. Якщо вона повертає помилку,
+// скрипт скасовується так само, як скрипт з помилками в командах.
+type postFunc func(ops ...Painter.Operation) error
+
+func (p *Parser) execute(in io.Reader, out io.Writer, post postFunc) ([]Painter.Operation, error) {
+	var cmds []commandLine
 
 	scanner := bufio.NewScanner(in)
 	scanner.Split(bufio.ScanLines)
-	for scanner.Scan() {
-		commandLine := scanner.Text()
-		op, err := p.process(commandLine)
+	for line := 1; scanner.Scan(); line++ {
+		fields := tokenize(scanner.Text())
+		if len(fields) > 0 {
+			cmds = append(cmds, commandLine{line: line, fields: fields})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return p.processAll(cmds, nil, out, post)
+}
+
+// commandLine команда скрипта разом з номером рядка, в якому вона записана.
+type commandLine struct {
+	line   int
+	fields []token
+}
+
+// processAll виконує команди та повертає їхні операції. errs містить помилки, знайдені до виконання: якщо вони є,
+// скрипт також не змінює стан. Якщо post не nil, операції відправляються через неї перед записом сцен.
+func (p *Parser) processAll(cmds []commandLine, errs ParseErrors, out io.Writer, post postFunc) ([]Painter.Operation, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var res []Painter.Operation
+	cp := p.checkpoint()
+	defer func() { p.saves = nil }()
+
+	for _, cmd := range cmds {
+		saves := len(p.saves)
+		op, err := p.process(cmd.fields, out)
+		for i := saves; i < len(p.saves); i++ {
+			p.saves[i].cmd = cmd
+		}
 
 		if err != nil {
-			return nil, err
+			errs = append(errs, newParseError(cmd.line, cmd.fields, err))
 		} else if op != nil {
 			res = append(res, op)
 		}
 	}
 
+	// Сцени записуються у два етапи, щоб помилка запису будь-якої з них не змінила жодного файлу.
+	for i := 0; i < len(p.saves) && len(errs) == 0; i++ {
+		if err := p.saves[i].stage(); err != nil {
+			errs = append(errs, newSaveError(p.saves[i], err))
+		}
+	}
+	if len(errs) > 0 {
+		p.discardSaves()
+		p.restore(cp)
+		return nil, errs
+	}
+	if post != nil {
+		if err := post(res...); err != nil {
+			p.discardSaves()
+			p.restore(cp)
+			return nil, err
+		}
+	}
+	for _, s := range p.saves {
+		if err := s.commit(); err != nil {
+			errs = append(errs, newSaveError(s, err))
+		}
+	}
+	if len(errs) > 0 {
+		p.restore(cp)
+		return nil, errs
+	}
 	return res, nil
 }
 
+func newSaveError(s pendingSave, err error) *ParseError {
+	return newParseError(s.cmd.line, s.cmd.fields, argError{pos: 0, err: err})
+}
+
+// ParseError описує помилку в одній команді скрипта.
+type ParseError struct {
+	Line    int    // Номер рядка, починаючи з 1.
+	Column  int    // Позиція проблемного токена в рядку, починаючи з 1.
+	Token   string // Проблемний токен.
+	Command string // Назва команди, в якій знайдено помилку.
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s: %q: %s", e.Line, e.Column, e.Command, e.Token, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ParseError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Line    int    `json:"line"`
+		Column  int    `json:"column"`
+		Token   string `json:"token"`
+		Command string `json:"command"`
+		Message string `json:"message"`
+	}{e.Line, e.Column, e.Token, e.Command, e.Err.Error()})
+}
+
+// ParseErrors містить усі помилки, знайдені у скрипті.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
 type countError struct{}
 
 func (e countError) Error() string {
 	return "Invalid argument count"
 }
 
-func (p *Parser) process(cmd string) (Painter.Operation, error) {
+// argError вказує, що помилка стосується аргументу команди з індексом pos.
+type argError struct {
+	pos int
+	err error
+}
+
+func (e argError) Error() string {
+	return e.err.Error()
+}
+
+func newParseError(line int, fields []token, err error) *ParseError {
+	pe := &ParseError{
+		Line:    line,
+		Column:  fields[0].col,
+		Token:   fields[0].text,
+		Command: fields[0].text,
+		Err:     err,
+	}
+	var ae argError
+	if errors.As(err, &ae) {
+		pe.Err = ae.err
+		if ae.pos+1 < len(fields) {
+			pe.Column = fields[ae.pos+1].col
+			pe.Token = fields[ae.pos+1].text
+		}
+	}
+	return pe
+}
+
+// token слово команди разом з його позицією в рядку.
+type token struct {
+	text string
+	col  int
+	// quoted встановлено для рядків у лапках, text у такому разі містить рядок без лапок.
+	quoted bool
+}
+
+// tokenize розбиває рядок на слова. Пробіли всередині дужок не розділяють слова, тож записи на кшталт
+// rgb(0, 128, 255) залишаються одним токеном. Слово, яке починається з одинарної чи подвійної лапки, триває до
+// відповідної закриваючої лапки, всередині можна екранувати лапку чи зворотну скісну риску символом \.
+func tokenize(line string) []token {
+	var res []token
+	start, depth := -1, 0
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if start < 0 && (c == '\'' || c == '"') {
+			text, n, ok := unquote(line[i:])
+			if !ok {
+				// Незакритий рядок займає залишок рядка, а помилку повідомляє команда.
+				return append(res, token{text: line[i:], col: i + 1})
+			}
+			res = append(res, token{text: text, col: i + 1, quoted: true})
+			i += n - 1
+			continue
+		}
+		space := (c == ' ' || c == '\t' || c == '\r') && depth == 0
+		if space && start >= 0 {
+			res = append(res, token{text: line[start:i], col: start + 1})
+			start = -1
+		} else if !space && start < 0 {
+			start = i
+		}
+		if c == '(' {
+			depth++
+		} else if c == ')' && depth > 0 {
+			depth--
+		}
+	}
+	if start >= 0 {
+		res = append(res, token{text: line[start:], col: start + 1})
+	}
+	return res
+}
+
+// unquote читає рядок у лапках з початку s. Повертає рядок без лапок та кількість прочитаних байтів, або false,
+// якщо закриваючої лапки немає.
+func unquote(s string) (string, int, bool) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == s[0]:
+			return b.String(), i + 1, true
+		case c == '\\' && i+1 < len(s) && (s[i+1] == s[0] || s[i+1] == '\\'):
+			i++
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, false
+}
+
+func (p *Parser) process(fields []token, out io.Writer) (Painter.Operation, error) {
 	var tweaker Painter.StateTweaker
 
-	fields := strings.Fields(cmd)
-	switch fields[0] {
+	args := fields[1:]
+	switch fields[0].text {
 	case "white":
-		if len(fields) > 1 {
-			return nil, countError{}
+		if err := noArguments(args); err != nil {
+			return nil, err
 		}
 		tweaker = Painter.OperationFill{Color: color.White}
 	case "green":
-		if len(fields) > 1 {
-			return nil, countError{}
+		if err := noArguments(args); err != nil {
+			return nil, err
 		}
 		tweaker = Painter.OperationFill{Color: color.RGBA{G: 0xff, A: 0xff}}
 	case "update":
-		if len(fields) > 1 {
-			return nil, countError{}
+		if err := noArguments(args); err != nil {
+			return nil, err
 		}
 		return Painter.UpdateOp, nil
+	case "fill":
+		if len(args) != 1 {
+			return nil, argError{pos: 1, err: countError{}}
+		}
+		c, err := parseColor(args[0].text)
+		if err != nil {
+			return nil, argError{pos: 0, err: err}
+		}
+		tweaker = Painter.OperationFill{Color: c}
 	case "bgrect":
-		args, err := processArguments(fields[1:], 4)
+		args, c, err := processColoredArguments(args, 4)
 		if err != nil {
 			return nil, err
 		}
 		tweaker = Painter.OperationBGRect{
-			Min: Painter.RelativePoint{X: args[0], Y: args[1]},
-			Max: Painter.RelativePoint{X: args[2], Y: args[3]},
+			Min:   Painter.RelativePoint{X: args[0], Y: args[1]},
+			Max:   Painter.RelativePoint{X: args[2], Y: args[3]},
+			Color: c,
+			Blend: p.blend,
+		}
+	case "blend":
+		if len(args) != 1 {
+			return nil, argError{pos: 1, err: countError{}}
+		}
+		mode, ok := Painter.ParseBlendMode(args[0].text)
+		if !ok {
+			return nil, argError{pos: 0, err: fmt.Errorf("unknown blend mode")}
 		}
-	case "figure":
-		args, err := processArguments(fields[1:], 2)
+		p.blend = mode
+		return nil, nil
+	case "figure", "circle", "ellipse", "line", "triangle", "polyline", "polygon":
+		op, err := p.processFigure(fields[0].text, args)
 		if err != nil {
 			return nil, err
 		}
-		tweaker = Painter.OperationFigure{
-			Center: Painter.RelativePoint{X: args[0], Y: args[1]},
+		tweaker = op
+	case "text":
+		op, err := p.processText(args)
+		if err != nil {
+			return nil, err
 		}
-	case "move":
-		args, err := processArguments(fields[1:], 2)
+		tweaker = op
+	case "image":
+		op, err := p.processImage(args)
 		if err != nil {
 			return nil, err
 		}
-		tweaker = Painter.MoveTweaker{
-			Offset: Painter.RelativePoint{X: args[0], Y: args[1]},
+		tweaker = op
+	case "remove":
+		if len(args) != 1 {
+			return nil, argError{pos: 1, err: countError{}}
 		}
-	case "reset":
-		if len(fields) > 1 {
+		id, _, err := p.splitFigureID(args, true)
+		if err != nil {
+			return nil, err
+		} else if id == "" {
+			return nil, argError{pos: 0, err: fmt.Errorf("invalid figure id")}
+		}
+		tweaker = Painter.RemoveFigureTweaker{ID: id}
+	case "list":
+		if err := noArguments(args); err != nil {
+			return nil, err
+		}
+		for _, f := range p.state.FigureOperations {
+			fmt.Fprintf(out, "%s %g %g\n", f.ID, f.Center.X, f.Center.Y)
+		}
+		return nil, nil
+	case "rmrect":
+		idx, err := p.processRectIndexes(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		tweaker = Painter.RemoveRectTweaker{Index: idx[0]}
+	case "clearrects":
+		if err := noArguments(args); err != nil {
+			return nil, err
+		}
+		tweaker = Painter.ClearRectsTweaker{}
+	case "moverect":
+		idx, err := p.processRectIndexes(args, 2)
+		if err != nil {
+			return nil, err
+		}
+		tweaker = Painter.ReorderRectTweaker{From: idx[0], To: idx[1]}
+	case "move", "moveto":
+		id, rest, err := p.splitFigureID(args, true)
+		if err != nil {
+			return nil, err
+		}
+		nums, err := processArguments(rest, 2)
+		if err != nil {
+			return nil, shiftArgError(err, len(args)-len(rest))
+		}
+		point := Painter.RelativePoint{X: nums[0], Y: nums[1]}
+		if fields[0].text == "move" {
+			tweaker = Painter.MoveTweaker{ID: id, Offset: point}
+		} else {
+			tweaker = Painter.MoveToTweaker{ID: id, Position: point}
+		}
+	case "resize":
+		if len(args) > 2 {
+			return nil, argError{pos: 2, err: countError{}}
+		} else if len(args) < 2 {
 			return nil, countError{}
 		}
+		var size [2]int
+		for i := range size {
+			n, err := strconv.Atoi(args[i].text)
+			if err != nil || n < 1 || n > MaxCanvasSize {
+				return nil, argError{pos: i, err: fmt.Errorf("size must be an integer in [1,%d] range", MaxCanvasSize)}
+			}
+			size[i] = n
+		}
+		return Painter.ResizeOp{Size: image.Pt(size[0], size[1])}, nil
+	case "undo", "redo":
+		n := 1
+		if len(args) > 1 {
+			return nil, argError{pos: 1, err: countError{}}
+		} else if len(args) == 1 {
+			var err error
+			if n, err = strconv.Atoi(args[0].text); err != nil || n < 1 {
+				return nil, argError{pos: 0, err: fmt.Errorf("count must be a positive integer")}
+			}
+		}
+		from, to := &p.undo, &p.redo
+		if fields[0].text == "redo" {
+			from, to = to, from
+		}
+		if !p.step(n, from, to) {
+			return nil, fmt.Errorf("nothing to %s", fields[0].text)
+		}
+		return p.snapshot(), nil
+	case "animate":
+		op, err := p.processAnimate(args)
+		if err != nil {
+			return nil, err
+		}
+		return op, nil
+	case "save", "load":
+		if len(args) != 1 {
+			return nil, argError{pos: 1, err: countError{}}
+		}
+		name := args[0].text
+		if !validID(name) {
+			return nil, argError{pos: 0, err: fmt.Errorf("invalid scene name")}
+		}
+		if fields[0].text == "save" {
+			save, err := p.prepareScene(name)
+			if err != nil {
+				return nil, argError{pos: 0, err: err}
+			}
+			p.saves = append(p.saves, save)
+			return nil, nil
+		}
+		state, err := p.loadScene(name)
+		if err != nil {
+			return nil, argError{pos: 0, err: err}
+		}
+		tweaker = sceneTweaker{state: state}
+	case "reset":
+		if err := noArguments(args); err != nil {
+			return nil, err
+		}
 		tweaker = Painter.ResetTweaker{}
 	default:
 		return nil, fmt.Errorf("unknown command")
 	}
 
 	if nil != tweaker {
+		p.remember()
 		p.state.Update(tweaker)
+		// Режим змішування змінюється після remember, щоб undo відновило режим, який був до reset.
+		if _, ok := tweaker.(Painter.ResetTweaker); ok {
+			p.blend = Painter.BlendOver
+		}
+	}
+	return p.snapshot(), nil
+}
+
+// snapshot повертає операцію з копією поточного стану. Подальші команди змінюють лише p.state, тож операції,
+// які вже відправлені в Loop, залишаються незмінними.
+func (p *Parser) snapshot() *Painter.StatefulOperationList {
+	state := p.state.Clone()
+	return &state
+}
+
+// remember зберігає поточний стан в історії перед його зміною. Нова зміна робить неможливим redo.
+func (p *Parser) remember() {
+	limit := p.HistorySize
+	if limit <= 0 {
+		limit = DefaultHistorySize
+	}
+	p.undo = append(p.undo, snapshot{state: p.state.Clone(), blend: p.blend})
+	if len(p.undo) > limit {
+		p.undo = append(p.undo[:0], p.undo[len(p.undo)-limit:]...)
+	}
+	p.redo = nil
+}
+
+// Undo скасовує останні n змін стану та повертає операцію з відновленим станом. Повертає false і нічого не
+// змінює, якщо в історії менше n змін.
+func (p *Parser) Undo(n int) (Painter.Operation, bool) {
+	op, ok, _ := p.undoAndPost(n, nil)
+	return op, ok
+}
+
+// Redo повторює останні n змін, скасованих Undo. Повертає false і нічого не змінює, якщо скасованих змін менше n.
+func (p *Parser) Redo(n int) (Painter.Operation, bool) {
+	op, ok, _ := p.redoAndPost(n, nil)
+	return op, ok
+}
+
+func (p *Parser) undoAndPost(n int, post postFunc) (Painter.Operation, bool, error) {
+	return p.travel(n, &p.undo, &p.redo, post)
+}
+
+func (p *Parser) redoAndPost(n int, post postFunc) (Painter.Operation, bool, error) {
+	return p.travel(n, &p.redo, &p.undo, post)
+}
+
+// travel виконує step і відправляє відновлений стан через post, якщо вона задана. Якщо post повертає помилку,
+// зміна скасовується.
+func (p *Parser) travel(n int, from, to *[]snapshot, post postFunc) (Painter.Operation, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cp := p.checkpoint()
+	if !p.step(n, from, to) {
+		return nil, false, nil
+	}
+	op := p.snapshot()
+	if post != nil {
+		if err := post(op); err != nil {
+			p.restore(cp)
+			return nil, true, err
+		}
+	}
+	return op, true, nil
+}
+
+// step переносить n станів зі стеку from у стек to, зберігаючи поточний стан у to.
+func (p *Parser) step(n int, from, to *[]snapshot) bool {
+	if n > len(*from) {
+		return false
+	}
+	for ; n > 0; n-- {
+		*to = append(*to, snapshot{state: p.state, blend: p.blend})
+		last := (*from)[len(*from)-1]
+		*from = (*from)[:len(*from)-1]
+		// Стани в історії не змінюються, бо на них посилається копія історії з checkpoint.
+		p.state, p.blend = last.state.Clone(), last.blend
+	}
+	return true
+}
+
+// checkpoint стан парсера разом з історією, до якого він повертається, якщо скрипт не виконано.
+type checkpoint struct {
+	current    snapshot
+	undo, redo []snapshot
+}
+
+func (p *Parser) checkpoint() checkpoint {
+	return checkpoint{
+		current: snapshot{state: p.state.Clone(), blend: p.blend},
+		undo:    slices.Clone(p.undo),
+		redo:    slices.Clone(p.redo),
+	}
+}
+
+func (p *Parser) restore(cp checkpoint) {
+	p.state, p.blend = cp.current.state, cp.current.blend
+	p.undo, p.redo = cp.undo, cp.redo
+}
+
+func (p *Parser) discardSaves() {
+	for i := range p.saves {
+		p.saves[i].discard()
+	}
+}
+
+// shapeArgs задає кількість числових аргументів команд фігур. Для ламаної та багатокутника вказано мінімальну
+// кількість, а загалом координат вершин може бути будь-яка парна кількість. optional задає кількість
+// необов'язкових числових аргументів, які можуть йти після обов'язкових.
+var shapeArgs = map[string]struct {
+	n        int
+	optional int
+	variable bool
+}{
+	"figure":   {n: 2, optional: 1},
+	"circle":   {n: 3},
+	"ellipse":  {n: 4},
+	"line":     {n: 4, optional: 1},
+	"triangle": {n: 6},
+	"polyline": {n: 4, optional: 1, variable: true},
+	"polygon":  {n: 6, variable: true},
+}
+
+// processFigure розбирає команду фігури виду "name [id] числа... [колір]". Для figure, circle та ellipse першими
+// йдуть координати центру, для інших фігур координати вершин. Для figure після центру можна вказати розмір
+// (див. Painter.TShape), а для line та polyline після вершин товщину лінії (див. Painter.Polyline).
+func (p *Parser) processFigure(name string, args []token) (Painter.OperationFigure, error) {
+	var op Painter.OperationFigure
+
+	id, rest, err := p.splitFigureID(args, false)
+	if err != nil {
+		return op, err
+	}
+	shift := len(args) - len(rest)
+	spec := shapeArgs[name]
+	if n := len(rest); n > spec.n && !isNumber(rest[n-1].text) {
+		if op.Color, err = parseColor(rest[n-1].text); err != nil {
+			return op, argError{pos: len(args) - 1, err: err}
+		}
+		rest = rest[:n-1]
+	}
+
+	required := spec.n
+	if len(rest) > spec.n && len(rest) <= spec.n+spec.optional {
+		required = len(rest)
+	} else if spec.variable && len(rest) > spec.n {
+		// Непарне число аргументів означає, що після координат вершин вказано необов'язковий аргумент.
+		if len(rest)%2 != 0 && spec.optional == 0 {
+			return op, countError{}
+		}
+		required = len(rest)
+	}
+	nums, err := processArguments(rest, required)
+	if err != nil {
+		return op, shiftArgError(err, shift)
+	}
+
+	op.ID = id
+	op.Blend = p.blend
+	switch name {
+	case "figure", "circle", "ellipse":
+		op.Center = Painter.RelativePoint{X: nums[0], Y: nums[1]}
+		for i := 2; i < len(nums); i++ {
+			if nums[i] <= 0 {
+				return op, argError{pos: shift + i, err: fmt.Errorf("size must be positive")}
+			}
+		}
+		if name == "figure" && len(nums) > 2 {
+			op.Shape = Painter.TShape{Size: nums[2]}
+		} else if name == "circle" {
+			op.Shape = Painter.Circle{R: nums[2]}
+		} else if name == "ellipse" {
+			op.Shape = Painter.Ellipse{RX: nums[2], RY: nums[3]}
+		}
+	default:
+		var width float64
+		if len(nums)%2 != 0 {
+			width = nums[len(nums)-1]
+			nums = nums[:len(nums)-1]
+			if width <= 0 {
+				return op, argError{pos: shift + len(nums), err: fmt.Errorf("width must be positive")}
+			}
+		}
+		points := make([]Painter.RelativePoint, len(nums)/2)
+		for i := range points {
+			points[i] = Painter.RelativePoint{X: nums[2*i], Y: nums[2*i+1]}
+		}
+		var offsets []Painter.RelativePoint
+		op.Center, offsets = Painter.Centroid(points)
+		if name == "line" || name == "polyline" {
+			op.Shape = Painter.Polyline{Points: offsets, Width: width}
+		} else {
+			op.Shape = Painter.Polygon{Points: offsets}
+		}
+	}
+	return op, nil
+}
+
+// processText розбирає команду "text x y розмір колір рядок". Рядок з пробілами записується в лапках.
+func (p *Parser) processText(args []token) (Painter.OperationText, error) {
+	var op Painter.OperationText
+	if len(args) > 5 {
+		return op, argError{pos: 5, err: countError{}}
+	} else if len(args) < 5 {
+		return op, countError{}
+	}
+	nums, err := processArguments(args[:3], 3)
+	if err != nil {
+		return op, err
+	}
+	if nums[2] <= 0 {
+		return op, argError{pos: 2, err: fmt.Errorf("size must be positive")}
+	}
+	if op.Color, err = parseColor(args[3].text); err != nil {
+		return op, argError{pos: 3, err: err}
+	}
+	if s := args[4]; !s.quoted && (strings.HasPrefix(s.text, "'") || strings.HasPrefix(s.text, "\"")) {
+		return op, argError{pos: 4, err: fmt.Errorf("unterminated string")}
+	}
+	op.Position = Painter.RelativePoint{X: nums[0], Y: nums[1]}
+	op.Size = nums[2]
+	op.Text = args[4].text
+	op.Blend = p.blend
+	return op, nil
+}
+
+// processImage розбирає команду "image назва x y [ширина висота]". Точка (x, y) задає лівий верхній кут
+// зображення. Якщо розмір не вказано, зображення малюється в натуральному розмірі.
+func (p *Parser) processImage(args []token) (Painter.OperationImage, error) {
+	var op Painter.OperationImage
+	if len(args) != 3 && len(args) != 5 {
+		if len(args) > 5 {
+			return op, argError{pos: 5, err: countError{}}
+		}
+		return op, countError{}
+	}
+	img, ok := p.Images.Image(args[0].text)
+	if !ok {
+		return op, argError{pos: 0, err: fmt.Errorf("unknown image")}
 	}
-	return &p.state, nil
+	nums, err := processArguments(args[1:], len(args)-1)
+	if err != nil {
+		return op, shiftArgError(err, 1)
+	}
+	for i := 2; i < len(nums); i++ {
+		if nums[i] <= 0 {
+			return op, argError{pos: i + 1, err: fmt.Errorf("size must be positive")}
+		}
+	}
+	op.Name = args[0].text
+	op.Image = img
+	op.Position = Painter.RelativePoint{X: nums[0], Y: nums[1]}
+	if len(nums) == 4 {
+		op.Size = Painter.RelativePoint{X: nums[2], Y: nums[3]}
+	}
+	return op, nil
+}
+
+// processAnimate розбирає команди "animate <id|all> to x y over тривалість [easing назва]" та
+// "animate <id|all> cancel". Фігури в стані парсера одразу переносяться в кінцеві точки, а проміжні положення
+// малює Loop (див. Painter.AnimateOp), починаючи з наступної команди update. Тривалість записується у форматі
+// time.ParseDuration, наприклад 2s чи 500ms.
+func (p *Parser) processAnimate(args []token) (Painter.Operation, error) {
+	if len(args) < 2 {
+		return nil, countError{}
+	}
+	var ids []string
+	if target := args[0].text; target == allFigures {
+		for _, f := range p.state.FigureOperations {
+			ids = append(ids, f.ID)
+		}
+	} else if p.state.Figure(target) != nil {
+		ids = []string{target}
+	} else {
+		return nil, argError{pos: 0, err: fmt.Errorf("unknown figure")}
+	}
+
+	switch args[1].text {
+	case "cancel":
+		if len(args) > 2 {
+			return nil, argError{pos: 2, err: countError{}}
+		}
+		return Painter.AnimateOp{State: p.snapshot(), Cancel: ids}, nil
+	case "to":
+	default:
+		return nil, argError{pos: 1, err: fmt.Errorf("expected \"to\" or \"cancel\"")}
+	}
+
+	if len(args) != 6 && len(args) != 8 {
+		if len(args) > 8 {
+			return nil, argError{pos: 8, err: countError{}}
+		}
+		return nil, countError{}
+	}
+	nums, err := processArguments(args[2:4], 2)
+	if err != nil {
+		return nil, shiftArgError(err, 2)
+	}
+	if args[4].text != "over" {
+		return nil, argError{pos: 4, err: fmt.Errorf("expected \"over\"")}
+	}
+	duration, err := time.ParseDuration(args[5].text)
+	if err != nil || duration <= 0 {
+		return nil, argError{pos: 5, err: fmt.Errorf("duration must be positive, e.g. 2s or 500ms")}
+	}
+	easing := Painter.EaseLinear
+	if len(args) == 8 {
+		if args[6].text != "easing" {
+			return nil, argError{pos: 6, err: fmt.Errorf("expected \"easing\"")}
+		}
+		var ok bool
+		if easing, ok = Painter.ParseEasing(args[7].text); !ok {
+			return nil, argError{pos: 7, err: fmt.Errorf("unknown easing")}
+		}
+	}
+
+	to := Painter.RelativePoint{X: nums[0], Y: nums[1]}.Clamp()
+	op := Painter.AnimateOp{}
+	p.remember()
+	for _, id := range ids {
+		f := p.state.Figure(id)
+		op.Animations = append(op.Animations, Painter.Animation{
+			ID: id, From: f.Center, To: to, Duration: duration, Easing: easing,
+		})
+		p.state.Update(Painter.MoveToTweaker{ID: id, Position: to})
+	}
+	op.State = p.snapshot()
+	return op, nil
+}
+
+// splitFigureID відокремлює необов'язковий ідентифікатор фігури, який записується першим аргументом команди.
+// Ідентифікатор починається з літери, тож його не можна сплутати з координатою, і не може бути словом all. Якщо
+// exists встановлено, фігура з таким ідентифікатором повинна існувати, інакше навпаки, ідентифікатор не повинен
+// бути зайнятим.
+func (p *Parser) splitFigureID(args []token, exists bool) (string, []token, error) {
+	if len(args) == 0 || isNumber(args[0].text) {
+		return "", args, nil
+	}
+	id := args[0].text
+	if !validFigureID(id) {
+		return "", nil, argError{pos: 0, err: fmt.Errorf("invalid figure id")}
+	}
+	if found := p.state.Figure(id) != nil; found != exists {
+		if exists {
+			return "", nil, argError{pos: 0, err: fmt.Errorf("unknown figure")}
+		}
+		return "", nil, argError{pos: 0, err: fmt.Errorf("figure already exists")}
+	}
+	return id, args[1:], nil
+}
+
+// allFigures ключове слово, яким команда animate позначає всі фігури. Його не можна використати як ідентифікатор.
+const allFigures = "all"
+
+func validFigureID(id string) bool {
+	return validID(id) && id != allFigures
+}
+
+func validID(id string) bool {
+	for i, r := range id {
+		letter := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		if i == 0 && !letter {
+			return false
+		}
+		if !letter && !(r >= '0' && r <= '9') && r != '_' && r != '-' {
+			return false
+		}
+	}
+	return id != ""
+}
+
+// shiftArgError зсуває позицію аргументу в помилці на n, якщо аргументи розбиралися не з початку команди.
+func shiftArgError(err error, n int) error {
+	var ae argError
+	if errors.As(err, &ae) {
+		ae.pos += n
+		return ae
+	}
+	return err
+}
+
+func noArguments(args []token) error {
+	if len(args) > 0 {
+		return argError{pos: 0, err: countError{}}
+	}
+	return nil
+}
+
+// processColoredArguments розбирає requiredLen чисел, після яких може йти колір. Якщо колір не вказано,
+// повертається nil, і операція використовує свій колір за замовчуванням.
+func processColoredArguments(args []token, requiredLen int) ([]float64, color.Color, error) {
+	if len(args) != requiredLen+1 || isNumber(args[requiredLen].text) {
+		nums, err := processArguments(args, requiredLen)
+		return nums, nil, err
+	}
+	nums, err := processArguments(args[:requiredLen], requiredLen)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := parseColor(args[requiredLen].text)
+	if err != nil {
+		return nil, nil, argError{pos: requiredLen, err: err}
+	}
+	return nums, c, nil
+}
+
+// processRectIndexes розбирає requiredLen позицій прямокутників у порядку малювання та перевіряє, що прямокутники
+// з такими позиціями існують.
+func (p *Parser) processRectIndexes(args []token, requiredLen int) ([]int, error) {
+	if len(args) > requiredLen {
+		return nil, argError{pos: requiredLen, err: countError{}}
+	} else if len(args) < requiredLen {
+		return nil, countError{}
+	}
+	var processed []int
+	for idx, arg := range args {
+		num, err := strconv.Atoi(arg.text)
+		if err != nil {
+			return nil, argError{pos: idx, err: fmt.Errorf("invalid index")}
+		}
+		if num < 0 || num >= len(p.state.BgRectOperations) {
+			return nil, argError{pos: idx, err: fmt.Errorf("no rectangle at index %d", num)}
+		}
+		processed = append(processed, num)
+	}
+	return processed, nil
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
 }
 
-func processArguments(args []string, requiredLen int) ([]float64, error) {
-	if len(args) != requiredLen {
+func processArguments(args []token, requiredLen int) ([]float64, error) {
+	if len(args) > requiredLen {
+		return nil, argError{pos: requiredLen, err: countError{}}
+	} else if len(args) < requiredLen {
 		return nil, countError{}
 	}
 	var processed []float64
 	for idx, arg := range args {
-		num, err := strconv.ParseFloat(arg, 64)
+		num, err := strconv.ParseFloat(arg.text, 64)
 		if err != nil {
-			return nil, fmt.Errorf("invalid argument at pos %d", idx)
+			return nil, argError{pos: idx, err: fmt.Errorf("invalid number")}
 		}
 		if num >= -1 && num <= 1 {
 			processed = append(processed, num)
 		} else {
-			return nil, fmt.Errorf("value at pos %d is not in [-1,1] range", idx)
+			return nil, argError{pos: idx, err: fmt.Errorf("value is not in [-1,1] range")}
 		}
 	}
 
@@ -2,8 +2,11 @@ package Lang
 
 import (
 	Painter "github.com/roman-mazur/architecture-lab-3/painter"
+	"image"
+	"image/color"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -125,10 +128,11 @@ func TestParser_Operation(t *testing.T) {
 				{Center: Painter.RelativePoint{X: 0.6, Y: 0.8}},
 				{Center: Painter.RelativePoint{X: 0.5, Y: 0.65}},
 			},
-			checkIdx: 2,
+			// Кожна операція містить власну копію стану, тож результат обох переміщень видно в останній.
+			checkIdx: 3,
 		},
 	}
-	delta := 0.99
+	delta := 1e-9
 
 	for _, test := range testTable {
 		p := &Parser{}
@@ -152,3 +156,364 @@ func TestParser_Operation(t *testing.T) {
 		}
 	}
 }
+
+func TestParser_Errors(t *testing.T) {
+	p := &Parser{}
+
+	ops, err := p.Parse(strings.NewReader("white\nfigure 0.5 abc\n\nunknown 1\n  bgrect 0 0 1 1 1\nmove 0.5"))
+	assert.Empty(t, ops)
+
+	var errs ParseErrors
+	if !assert.ErrorAs(t, err, &errs) {
+		return
+	}
+	assert.Len(t, errs, 4)
+
+	assert.Equal(t, 2, errs[0].Line)
+	assert.Equal(t, 12, errs[0].Column)
+	assert.Equal(t, "abc", errs[0].Token)
+	assert.Equal(t, "figure", errs[0].Command)
+
+	assert.Equal(t, 4, errs[1].Line)
+	assert.Equal(t, 1, errs[1].Column)
+	assert.Equal(t, "unknown", errs[1].Command)
+
+	assert.Equal(t, 5, errs[2].Line)
+	assert.Equal(t, 18, errs[2].Column)
+	assert.Equal(t, "1", errs[2].Token)
+	assert.IsType(t, countError{}, errs[2].Err)
+
+	assert.Equal(t, 6, errs[3].Line)
+	assert.Equal(t, "move", errs[3].Token)
+}
+
+func TestParser_FailedScript(t *testing.T) {
+	p := &Parser{}
+	_, err := p.Parse(strings.NewReader("figure a 0.1 0.1\nblend multiply\nfigure b 0.2 0.2\nundo"))
+	assert.Nil(t, err)
+
+	// Жодна команда скрипта з помилкою не змінює стан, режим змішування чи історію.
+	_, err = p.Parse(strings.NewReader("figure c 0.5 0.5\nmoveto a 0.9 0.9\nblend src\nredo\nundo\nbogus"))
+	assert.Error(t, err)
+
+	var out strings.Builder
+	_, err = p.Execute(strings.NewReader("list"), &out)
+	assert.Nil(t, err)
+	assert.Equal(t, "a 0.1 0.1\n", out.String())
+
+	ops, err := p.Parse(strings.NewReader("redo\nbgrect 0 0 1 1"))
+	assert.Nil(t, err)
+	st := ops[1].(*Painter.StatefulOperationList)
+	assert.Equal(t, Painter.BlendMultiply, st.BgRectOperations[0].Blend)
+	assert.NotNil(t, st.Figure("b"))
+	assert.Equal(t, Painter.RelativePoint{X: 0.1, Y: 0.1}, st.Figure("a").Center)
+}
+
+func TestParser_Rects(t *testing.T) {
+	p := &Parser{}
+	script := "bgrect 0 0 0.5 0.5 red\nbgrect 0.1 0.1 0.6 0.6 green\nbgrect 0.2 0.2 0.7 0.7 blue\n" +
+		"moverect 2 0\nrmrect 1"
+	ops, err := p.Parse(strings.NewReader(script))
+	assert.Nil(t, err)
+
+	st := ops[len(ops)-1].(*Painter.StatefulOperationList)
+	if assert.Len(t, st.BgRectOperations, 2) {
+		assert.Equal(t, Painter.RelativePoint{X: 0.2, Y: 0.2}, st.BgRectOperations[0].Min)
+		assert.Equal(t, Painter.RelativePoint{X: 0.1, Y: 0.1}, st.BgRectOperations[1].Min)
+	}
+
+	_, err = p.Parse(strings.NewReader("rmrect 2\nmoverect 0 x"))
+	var errs ParseErrors
+	if assert.ErrorAs(t, err, &errs) && assert.Len(t, errs, 2) {
+		assert.Equal(t, "2", errs[0].Token)
+		assert.Equal(t, "x", errs[1].Token)
+	}
+
+	ops, err = p.Parse(strings.NewReader("clearrects"))
+	assert.Nil(t, err)
+	assert.Empty(t, ops[0].(*Painter.StatefulOperationList).BgRectOperations)
+}
+
+func TestParser_FigureIDs(t *testing.T) {
+	p := &Parser{}
+	var out strings.Builder
+	ops, err := p.Execute(strings.NewReader("figure 0.1 0.1\nfigure star 0.2 0.2 red\nfigure 0.3 0.3\n"+
+		"moveto star 0.5 0.6\nremove f1\nlist"), &out)
+	assert.Nil(t, err)
+	assert.Len(t, ops, 5)
+	assert.Equal(t, "star 0.5 0.6\nf2 0.3 0.3\n", out.String())
+
+	st := ops[len(ops)-1].(*Painter.StatefulOperationList)
+	assert.Equal(t, "star", st.FigureOperations[0].ID)
+	assert.Equal(t, Painter.RelativePoint{X: 0.3, Y: 0.3}, st.Figure("f2").Center)
+	assert.Nil(t, st.Figure("f1"))
+
+	_, err = p.Parse(strings.NewReader("figure star 0 0\nmove nope 0 0\nremove 1\nfigure 1abc 0 0\nmove star 0 x"))
+	var errs ParseErrors
+	if assert.ErrorAs(t, err, &errs) && assert.Len(t, errs, 5) {
+		assert.EqualError(t, errs[0].Err, "figure already exists")
+		assert.EqualError(t, errs[1].Err, "unknown figure")
+		assert.EqualError(t, errs[2].Err, "invalid figure id")
+		assert.Equal(t, "1abc", errs[3].Token)
+		assert.Equal(t, "x", errs[4].Token)
+		assert.Equal(t, 13, errs[4].Column)
+	}
+}
+
+func TestParser_MoveClamping(t *testing.T) {
+	p := &Parser{}
+	ops, err := p.Parse(strings.NewReader("figure a 0.9 0.1\nfigure b 0.2 0.2\nmove 0.5 -0.5\nmoveto b -0.5 0.5"))
+	assert.Nil(t, err)
+
+	st := ops[len(ops)-1].(*Painter.StatefulOperationList)
+	assert.Equal(t, Painter.RelativePoint{X: 1, Y: 0}, st.Figure("a").Center)
+	assert.Equal(t, Painter.RelativePoint{X: 0, Y: 0.5}, st.Figure("b").Center)
+}
+
+func TestParser_Shapes(t *testing.T) {
+	p := &Parser{}
+	ops, err := p.Parse(strings.NewReader("circle c 0.5 0.5 0.1 red\nellipse 0.5 0.5 0.2 0.1\nline 0 0 1 1\n" +
+		"triangle 0 0 0.6 0 0 0.3 #ff0000\npolyline 0 0 0.2 0.2 0.4 0\npolygon 0 0 1 0 1 1 0 1"))
+	assert.Nil(t, err)
+
+	st := ops[len(ops)-1].(*Painter.StatefulOperationList)
+	if !assert.Len(t, st.FigureOperations, 6) {
+		return
+	}
+	assert.Equal(t, Painter.Circle{R: 0.1}, st.Figure("c").Shape)
+	assert.Equal(t, Painter.Ellipse{RX: 0.2, RY: 0.1}, st.FigureOperations[1].Shape)
+	assert.Equal(t, Painter.RelativePoint{X: 0.5, Y: 0.5}, st.FigureOperations[2].Center)
+	assert.Equal(t, Painter.Polyline{Points: []Painter.RelativePoint{{X: -0.5, Y: -0.5}, {X: 0.5, Y: 0.5}}},
+		st.FigureOperations[2].Shape)
+	assert.InDelta(t, 0.2, st.FigureOperations[3].Center.X, 1e-9)
+	assert.InDelta(t, 0.1, st.FigureOperations[3].Center.Y, 1e-9)
+	assert.IsType(t, Painter.Polygon{}, st.FigureOperations[3].Shape)
+	assert.Len(t, st.FigureOperations[4].Shape.(Painter.Polyline).Points, 3)
+	assert.Len(t, st.FigureOperations[5].Shape.(Painter.Polygon).Points, 4)
+
+	_, err = p.Parse(strings.NewReader("circle 0.5 0.5 -0.1\npolygon 0 0 1 0 1\nline 0 0 1 1 1 1\ntriangle 0 0 1\n" +
+		"line 0 0 1 1 0\npolyline 0 0 1 1 0.5 0.5 -1 red"))
+	var errs ParseErrors
+	if assert.ErrorAs(t, err, &errs) && assert.Len(t, errs, 6) {
+		assert.Equal(t, "-0.1", errs[0].Token)
+		assert.IsType(t, countError{}, errs[1].Err)
+		assert.Equal(t, "1", errs[2].Token)
+		assert.Equal(t, 14, errs[2].Column)
+		assert.IsType(t, countError{}, errs[3].Err)
+		assert.EqualError(t, errs[4].Err, "width must be positive")
+		assert.Equal(t, "0", errs[4].Token)
+		assert.Equal(t, "-1", errs[5].Token)
+	}
+}
+
+func TestParser_LineWidth(t *testing.T) {
+	p := &Parser{}
+	ops, err := p.Parse(strings.NewReader("line 0 0 1 1 0.02 red\npolyline l 0 0 0.2 0.2 0.4 0 0.01"))
+	assert.Nil(t, err)
+	st := ops[len(ops)-1].(*Painter.StatefulOperationList)
+	if assert.Len(t, st.FigureOperations, 2) {
+		assert.Equal(t, 0.02, st.FigureOperations[0].Shape.(Painter.Polyline).Width)
+		assert.Equal(t, color.RGBA{R: 0xff, A: 0xff}, st.FigureOperations[0].Color)
+		line := st.Figure("l").Shape.(Painter.Polyline)
+		assert.Equal(t, 0.01, line.Width)
+		assert.Len(t, line.Points, 3)
+	}
+
+	ops, err = p.ParseJSON(strings.NewReader(`[{"op": "line", "x1": 0, "y1": 0, "x2": 1, "y2": 1, "width": 0.03}]`))
+	assert.Nil(t, err)
+	st = ops[0].(*Painter.StatefulOperationList)
+	assert.Equal(t, 0.03, st.FigureOperations[2].Shape.(Painter.Polyline).Width)
+}
+
+func TestParser_FigureSize(t *testing.T) {
+	p := &Parser{}
+	ops, err := p.Parse(strings.NewReader("figure 0.5 0.5 0.25\nfigure 0.5 0.5 0.1 red\nfigure 0.5 0.5 red"))
+	assert.Nil(t, err)
+
+	st := ops[len(ops)-1].(*Painter.StatefulOperationList)
+	assert.Equal(t, Painter.TShape{Size: 0.25}, st.FigureOperations[0].Shape)
+	assert.Equal(t, Painter.TShape{Size: 0.1}, st.FigureOperations[1].Shape)
+	assert.Nil(t, st.FigureOperations[2].Shape)
+
+	_, err = p.Parse(strings.NewReader("figure 0.5 0.5 0"))
+	assert.ErrorContains(t, err, "size must be positive")
+}
+
+func TestParser_Resize(t *testing.T) {
+	p := &Parser{}
+	ops, err := p.Parse(strings.NewReader("resize 800 600"))
+	assert.Nil(t, err)
+	assert.Equal(t, []Painter.Operation{Painter.ResizeOp{Size: image.Pt(800, 600)}}, ops)
+
+	_, err = p.Parse(strings.NewReader("resize 0 10\nresize 10\nresize 10 0.5"))
+	var errs ParseErrors
+	if assert.ErrorAs(t, err, &errs) && assert.Len(t, errs, 3) {
+		assert.Equal(t, "0", errs[0].Token)
+		assert.IsType(t, countError{}, errs[1].Err)
+		assert.Equal(t, "0.5", errs[2].Token)
+	}
+}
+
+func TestParser_Blend(t *testing.T) {
+	p := &Parser{}
+	ops, err := p.Parse(strings.NewReader("bgrect 0 0 1 1\nblend multiply\nbgrect 0 0 1 1 #ff000080\nfigure 0.5 0.5"))
+	assert.Nil(t, err)
+	assert.Len(t, ops, 3)
+
+	st := ops[2].(*Painter.StatefulOperationList)
+	assert.Equal(t, Painter.BlendOver, st.BgRectOperations[0].Blend)
+	assert.Equal(t, Painter.BlendMultiply, st.BgRectOperations[1].Blend)
+	assert.Equal(t, Painter.BlendMultiply, st.FigureOperations[0].Blend)
+
+	ops, err = p.Parse(strings.NewReader("reset\nfigure 0.5 0.5"))
+	assert.Nil(t, err)
+	st = ops[1].(*Painter.StatefulOperationList)
+	assert.Equal(t, Painter.BlendOver, st.FigureOperations[0].Blend)
+
+	_, err = p.Parse(strings.NewReader("blend xor"))
+	assert.ErrorContains(t, err, "unknown blend mode")
+}
+
+func TestParser_Text(t *testing.T) {
+	p := &Parser{}
+	ops, err := p.Parse(strings.NewReader("text 0.1 0.2 0.05 red 'Hello, world'\n" +
+		"text 0.1 0.3 0.05 black \"it's \\\"quoted\\\"\"\ntext 0.1 0.4 0.05 blue label"))
+	assert.Nil(t, err)
+
+	st := ops[len(ops)-1].(*Painter.StatefulOperationList)
+	if !assert.Len(t, st.TextOperations, 3) {
+		return
+	}
+	assert.Equal(t, Painter.RelativePoint{X: 0.1, Y: 0.2}, st.TextOperations[0].Position)
+	assert.Equal(t, 0.05, st.TextOperations[0].Size)
+	assert.Equal(t, "Hello, world", st.TextOperations[0].Text)
+	assert.Equal(t, `it's "quoted"`, st.TextOperations[1].Text)
+	assert.Equal(t, "label", st.TextOperations[2].Text)
+
+	ops, err = p.Parse(strings.NewReader("reset"))
+	assert.Nil(t, err)
+	assert.Empty(t, ops[0].(*Painter.StatefulOperationList).TextOperations)
+
+	_, err = p.Parse(strings.NewReader("text 0.1 0.1 0 red hi\ntext 0.1 0.1 0.1 red 'hi there\ntext 0.1 0.1 0.1 red a b"))
+	var errs ParseErrors
+	if assert.ErrorAs(t, err, &errs) && assert.Len(t, errs, 3) {
+		assert.Equal(t, "0", errs[0].Token)
+		assert.Equal(t, "'hi there", errs[1].Token)
+		assert.Equal(t, 22, errs[1].Column)
+		assert.IsType(t, countError{}, errs[2].Err)
+	}
+}
+
+func TestParser_UndoRedo(t *testing.T) {
+	p := &Parser{}
+	ops, err := p.Parse(strings.NewReader("figure a 0.1 0.1\nfigure b 0.2 0.2\nmoveto a 0.5 0.5\nreset\nundo"))
+	assert.Nil(t, err)
+	st := ops[len(ops)-1].(*Painter.StatefulOperationList)
+	if assert.Len(t, st.FigureOperations, 2) {
+		assert.Equal(t, Painter.RelativePoint{X: 0.5, Y: 0.5}, st.Figure("a").Center)
+	}
+
+	ops, err = p.Parse(strings.NewReader("undo 2"))
+	assert.Nil(t, err)
+	st = ops[0].(*Painter.StatefulOperationList)
+	if assert.Len(t, st.FigureOperations, 1) {
+		assert.Equal(t, Painter.RelativePoint{X: 0.1, Y: 0.1}, st.Figure("a").Center)
+	}
+
+	ops, err = p.Parse(strings.NewReader("redo"))
+	assert.Nil(t, err)
+	st = ops[0].(*Painter.StatefulOperationList)
+	assert.Len(t, st.FigureOperations, 2)
+	assert.Equal(t, Painter.RelativePoint{X: 0.1, Y: 0.1}, st.Figure("a").Center)
+
+	// Нова зміна скидає скасовані зміни.
+	_, err = p.Parse(strings.NewReader("remove b"))
+	assert.Nil(t, err)
+	_, err = p.Parse(strings.NewReader("redo\nundo 5\nundo 0"))
+	var errs ParseErrors
+	if assert.ErrorAs(t, err, &errs) && assert.Len(t, errs, 3) {
+		assert.EqualError(t, errs[0].Err, "nothing to redo")
+		assert.EqualError(t, errs[1].Err, "nothing to undo")
+		assert.Equal(t, "0", errs[2].Token)
+	}
+}
+
+func TestParser_HistorySize(t *testing.T) {
+	p := &Parser{HistorySize: 2}
+	_, err := p.Parse(strings.NewReader("figure a 0.1 0.1\nfigure b 0.2 0.2\nfigure c 0.3 0.3\nundo 2"))
+	assert.Nil(t, err)
+	var out strings.Builder
+	_, err = p.Execute(strings.NewReader("list"), &out)
+	assert.Nil(t, err)
+	assert.Equal(t, "a 0.1 0.1\n", out.String())
+	_, err = p.Parse(strings.NewReader("undo"))
+	assert.Error(t, err)
+
+	p = &Parser{HistorySize: -1}
+	_, err = p.Parse(strings.NewReader("figure a 0.1 0.1\nfigure b 0.2 0.2\nundo 2"))
+	assert.Nil(t, err)
+}
+
+func TestParser_UndoReset(t *testing.T) {
+	p := &Parser{}
+	ops, err := p.Parse(strings.NewReader("blend multiply\nreset\nundo\nbgrect 0 0 1 1"))
+	assert.Nil(t, err)
+	st := ops[len(ops)-1].(*Painter.StatefulOperationList)
+	assert.Equal(t, Painter.BlendMultiply, st.BgRectOperations[0].Blend)
+}
+
+func TestParser_Snapshots(t *testing.T) {
+	p := &Parser{}
+	ops, err := p.Parse(strings.NewReader("figure a 0.1 0.1\nmoveto a 0.5 0.5"))
+	assert.Nil(t, err)
+	first := ops[0].(*Painter.StatefulOperationList)
+
+	_, err = p.Parse(strings.NewReader("moveto a 0.9 0.9\nbgrect 0 0 1 1\nreset"))
+	assert.Nil(t, err)
+	assert.Equal(t, Painter.RelativePoint{X: 0.1, Y: 0.1}, first.Figure("a").Center)
+	assert.Equal(t, Painter.RelativePoint{X: 0.5, Y: 0.5}, ops[1].(*Painter.StatefulOperationList).Figure("a").Center)
+	assert.Empty(t, first.BgRectOperations)
+}
+
+func TestParser_Animate(t *testing.T) {
+	p := &Parser{}
+	ops, err := p.Parse(strings.NewReader("figure a 0.1 0.1\nfigure b 0.2 0.2\n" +
+		"animate a to 0.5 0.6 over 2s easing ease-in-out\nanimate all to 0.9 0.9 over 500ms\nanimate b cancel"))
+	assert.Nil(t, err)
+	if !assert.Len(t, ops, 5) {
+		return
+	}
+
+	first := ops[2].(Painter.AnimateOp)
+	assert.Equal(t, []Painter.Animation{{
+		ID: "a", From: Painter.RelativePoint{X: 0.1, Y: 0.1}, To: Painter.RelativePoint{X: 0.5, Y: 0.6},
+		Duration: 2 * time.Second, Easing: Painter.EaseInOut,
+	}}, first.Animations)
+	assert.Equal(t, Painter.RelativePoint{X: 0.5, Y: 0.6}, first.State.Figure("a").Center)
+
+	// Друга анімація фігури a починається там, де закінчується перша.
+	all := ops[3].(Painter.AnimateOp)
+	if assert.Len(t, all.Animations, 2) {
+		assert.Equal(t, Painter.RelativePoint{X: 0.5, Y: 0.6}, all.Animations[0].From)
+		assert.Equal(t, Painter.RelativePoint{X: 0.2, Y: 0.2}, all.Animations[1].From)
+		assert.Equal(t, Painter.EaseLinear, all.Animations[1].Easing)
+	}
+	assert.Equal(t, []string{"b"}, ops[4].(Painter.AnimateOp).Cancel)
+	assert.Equal(t, Painter.RelativePoint{X: 0.9, Y: 0.9}, ops[4].(Painter.AnimateOp).State.Figure("b").Center)
+
+	_, err = p.Parse(strings.NewReader("animate c to 0 0 over 1s\nanimate a by 0 0 over 1s\n" +
+		"animate a to 0 0 in 1s\nanimate a to 0 0 over -1s\nanimate a to 0 0 over 1s easing bounce\n" +
+		"animate a to 0 0\nanimate a cancel now\nfigure all 0.5 0.5"))
+	var errs ParseErrors
+	if assert.ErrorAs(t, err, &errs) && assert.Len(t, errs, 8) {
+		assert.Equal(t, "c", errs[0].Token)
+		assert.Equal(t, "by", errs[1].Token)
+		assert.Equal(t, "in", errs[2].Token)
+		assert.Equal(t, "-1s", errs[3].Token)
+		assert.Equal(t, "bounce", errs[4].Token)
+		assert.IsType(t, countError{}, errs[5].Err)
+		assert.Equal(t, "now", errs[6].Token)
+		// all зарезервовано для animate, тож не може бути ідентифікатором фігури.
+		assert.EqualError(t, errs[7].Err, "invalid figure id")
+	}
+}
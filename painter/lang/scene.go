@@ -0,0 +1,382 @@
+package Lang
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/roman-mazur/architecture-lab-3/painter"
+	"image/color"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SceneVersion версія формату файлів сцен.
+const SceneVersion = 1
+
+// scene описує формат, у якому команда save зберігає стан малюнку у файл <ScenesDir>/<назва>.json:
+//
+//	{
+//	  "version": 1,
+//	  "background": "#ffffffff",
+//	  "rects": [{"min": [0, 0], "max": [0.5, 0.5], "color": "#ff0000ff", "blend": "over"}],
+//	  "figures": [{"id": "f1", "center": [0.5, 0.5], "color": "#0036ceff", "blend": "over",
+//	               "shape": {"type": "circle", "r": 0.1}}],
+//	  "images": [{"name": "logo", "position": [0.1, 0.1], "size": [0.2, 0.1]}],
+//	  "texts": [{"position": [0.1, 0.9], "size": 0.05, "color": "#000000ff", "text": "Hello", "blend": "over"}]
+//	}
+//
+// Точки записуються масивами [x, y] у відносних координатах, кольори у форматі #RRGGBBAA без попереднього
+// множення на прозорість. Відсутній колір означає колір за замовчуванням, відсутня фігура "shape" означає
+// фігуру T розміру за замовчуванням. Тип фігури визначає її параметри: "t" (size), "circle" (r),
+// "ellipse" (rx, ry), "polyline" (points, width) та "polygon" (points), де points задають зміщення вершин від
+// центру. Зображення зберігаються лише за назвою і під час завантаження беруться з Parser.Images.
+type scene struct {
+	Version    int           `json:"version"`
+	Background string        `json:"background,omitempty"`
+	Rects      []sceneRect   `json:"rects,omitempty"`
+	Figures    []sceneFigure `json:"figures,omitempty"`
+	Images     []sceneImage  `json:"images,omitempty"`
+	Texts      []sceneText   `json:"texts,omitempty"`
+}
+
+type scenePoint [2]float64
+
+type sceneRect struct {
+	Min   scenePoint `json:"min"`
+	Max   scenePoint `json:"max"`
+	Color string     `json:"color,omitempty"`
+	Blend string     `json:"blend,omitempty"`
+}
+
+type sceneFigure struct {
+	ID     string      `json:"id"`
+	Center scenePoint  `json:"center"`
+	Color  string      `json:"color,omitempty"`
+	Blend  string      `json:"blend,omitempty"`
+	Shape  *sceneShape `json:"shape,omitempty"`
+}
+
+type sceneShape struct {
+	Type   string       `json:"type"`
+	Size   float64      `json:"size,omitempty"`
+	R      float64      `json:"r,omitempty"`
+	RX     float64      `json:"rx,omitempty"`
+	RY     float64      `json:"ry,omitempty"`
+	Points []scenePoint `json:"points,omitempty"`
+	Width  float64      `json:"width,omitempty"`
+}
+
+type sceneImage struct {
+	Name     string     `json:"name"`
+	Position scenePoint `json:"position"`
+	Size     scenePoint `json:"size"`
+}
+
+type sceneText struct {
+	Position scenePoint `json:"position"`
+	Size     float64    `json:"size"`
+	Color    string     `json:"color,omitempty"`
+	Text     string     `json:"text"`
+	Blend    string     `json:"blend,omitempty"`
+}
+
+// sceneTweaker замінює весь стан малюнку станом, завантаженим зі сцени.
+type sceneTweaker struct {
+	state Painter.StatefulOperationList
+}
+
+func (t sceneTweaker) SetState(sol *Painter.StatefulOperationList) {
+	*sol = t.state
+}
+
+// pendingSave сцена, яку команда save записує у файл лише після успішного виконання всього скрипта.
+type pendingSave struct {
+	cmd  commandLine
+	path string
+	data []byte
+	tmp  string // Тимчасовий файл з даними сцени, який створює stage.
+}
+
+// prepareScene кодує поточний стан для запису у файл сцени name.
+func (p *Parser) prepareScene(name string) (pendingSave, error) {
+	path, err := p.scenePath(name)
+	if err != nil {
+		return pendingSave{}, err
+	}
+	sc, err := encodeScene(&p.state)
+	if err != nil {
+		return pendingSave{}, err
+	}
+	data, err := json.MarshalIndent(sc, "", "  ")
+	if err != nil {
+		return pendingSave{}, err
+	}
+	return pendingSave{path: path, data: append(data, '\n')}, nil
+}
+
+// stage записує сцену в тимчасовий файл поруч з файлом сцени, щоб збій не пошкодив попередню версію сцени.
+func (s *pendingSave) stage() error {
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, strings.TrimSuffix(filepath.Base(s.path), ".json")+".*.tmp")
+	if err != nil {
+		return err
+	}
+	s.tmp = tmp.Name()
+	_, err = tmp.Write(s.data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		s.discard()
+	}
+	return err
+}
+
+// commit замінює файл сцени тимчасовим файлом.
+func (s *pendingSave) commit() error {
+	err := os.Rename(s.tmp, s.path)
+	if err != nil {
+		s.discard()
+	}
+	return err
+}
+
+// discard видаляє тимчасовий файл, якщо його створено.
+func (s *pendingSave) discard() {
+	if s.tmp != "" {
+		_ = os.Remove(s.tmp)
+		s.tmp = ""
+	}
+}
+
+// loadScene читає стан з файлу сцени.
+func (p *Parser) loadScene(name string) (Painter.StatefulOperationList, error) {
+	var sc scene
+	path, err := p.scenePath(name)
+	if err != nil {
+		return Painter.StatefulOperationList{}, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Painter.StatefulOperationList{}, fmt.Errorf("unknown scene")
+	} else if err != nil {
+		return Painter.StatefulOperationList{}, err
+	}
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return Painter.StatefulOperationList{}, fmt.Errorf("invalid scene: %w", err)
+	}
+	state, err := p.decodeScene(&sc)
+	if err != nil {
+		return Painter.StatefulOperationList{}, fmt.Errorf("invalid scene: %w", err)
+	}
+	return state, nil
+}
+
+func (p *Parser) scenePath(name string) (string, error) {
+	if p.ScenesDir == "" {
+		return "", fmt.Errorf("scenes are not configured")
+	}
+	return filepath.Join(p.ScenesDir, name+".json"), nil
+}
+
+func encodeScene(sol *Painter.StatefulOperationList) (*scene, error) {
+	sc := &scene{Version: SceneVersion}
+	if fill, ok := sol.BgOperation.(Painter.OperationFill); ok {
+		sc.Background = encodeColor(fill.Color)
+	} else if sol.BgOperation != nil {
+		return nil, fmt.Errorf("unsupported background")
+	}
+	for _, r := range sol.BgRectOperations {
+		sc.Rects = append(sc.Rects, sceneRect{
+			Min:   encodePoint(r.Min),
+			Max:   encodePoint(r.Max),
+			Color: encodeColor(r.Color),
+			Blend: r.Blend.String(),
+		})
+	}
+	for _, f := range sol.FigureOperations {
+		shape, err := encodeShape(f.Shape)
+		if err != nil {
+			return nil, fmt.Errorf("figure %s: %w", f.ID, err)
+		}
+		sc.Figures = append(sc.Figures, sceneFigure{
+			ID:     f.ID,
+			Center: encodePoint(f.Center),
+			Color:  encodeColor(f.Color),
+			Blend:  f.Blend.String(),
+			Shape:  shape,
+		})
+	}
+	for _, img := range sol.ImageOperations {
+		sc.Images = append(sc.Images, sceneImage{
+			Name:     img.Name,
+			Position: encodePoint(img.Position),
+			Size:     encodePoint(img.Size),
+		})
+	}
+	for _, t := range sol.TextOperations {
+		sc.Texts = append(sc.Texts, sceneText{
+			Position: encodePoint(t.Position),
+			Size:     t.Size,
+			Color:    encodeColor(t.Color),
+			Text:     t.Text,
+			Blend:    t.Blend.String(),
+		})
+	}
+	return sc, nil
+}
+
+func encodeShape(s Painter.Shape) (*sceneShape, error) {
+	switch s := s.(type) {
+	case nil:
+		return nil, nil
+	case Painter.TShape:
+		return &sceneShape{Type: "t", Size: s.Size}, nil
+	case Painter.Circle:
+		return &sceneShape{Type: "circle", R: s.R}, nil
+	case Painter.Ellipse:
+		return &sceneShape{Type: "ellipse", RX: s.RX, RY: s.RY}, nil
+	case Painter.Polyline:
+		return &sceneShape{Type: "polyline", Points: encodePoints(s.Points), Width: s.Width}, nil
+	case Painter.Polygon:
+		return &sceneShape{Type: "polygon", Points: encodePoints(s.Points)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported shape %T", s)
+	}
+}
+
+func (p *Parser) decodeScene(sc *scene) (Painter.StatefulOperationList, error) {
+	var sol Painter.StatefulOperationList
+	if sc.Version != SceneVersion {
+		return sol, fmt.Errorf("unsupported version %d", sc.Version)
+	}
+	if sc.Background != "" {
+		c, err := parseColor(sc.Background)
+		if err != nil {
+			return sol, fmt.Errorf("background: %w", err)
+		}
+		sol.BgOperation = Painter.OperationFill{Color: c}
+	}
+	for i, r := range sc.Rects {
+		c, blend, err := decodeStyle(r.Color, r.Blend)
+		if err != nil {
+			return sol, fmt.Errorf("rect %d: %w", i, err)
+		}
+		sol.BgRectOperations = append(sol.BgRectOperations, Painter.OperationBGRect{
+			Min: decodePoint(r.Min), Max: decodePoint(r.Max), Color: c, Blend: blend,
+		})
+	}
+	sol.FigureOperations = []*Painter.OperationFigure{}
+	for _, f := range sc.Figures {
+		if !validFigureID(f.ID) || sol.Figure(f.ID) != nil {
+			return sol, fmt.Errorf("invalid figure id %q", f.ID)
+		}
+		c, blend, err := decodeStyle(f.Color, f.Blend)
+		if err != nil {
+			return sol, fmt.Errorf("figure %s: %w", f.ID, err)
+		}
+		shape, err := decodeShape(f.Shape)
+		if err != nil {
+			return sol, fmt.Errorf("figure %s: %w", f.ID, err)
+		}
+		sol.FigureOperations = append(sol.FigureOperations, &Painter.OperationFigure{
+			ID: f.ID, Center: decodePoint(f.Center), Color: c, Shape: shape, Blend: blend,
+		})
+	}
+	for _, img := range sc.Images {
+		data, ok := p.Images.Image(img.Name)
+		if !ok {
+			return sol, fmt.Errorf("unknown image %q", img.Name)
+		}
+		sol.ImageOperations = append(sol.ImageOperations, Painter.OperationImage{
+			Name: img.Name, Image: data, Position: decodePoint(img.Position), Size: decodePoint(img.Size),
+		})
+	}
+	for i, t := range sc.Texts {
+		c, blend, err := decodeStyle(t.Color, t.Blend)
+		if err != nil {
+			return sol, fmt.Errorf("text %d: %w", i, err)
+		}
+		sol.TextOperations = append(sol.TextOperations, Painter.OperationText{
+			Position: decodePoint(t.Position), Size: t.Size, Color: c, Text: t.Text, Blend: blend,
+		})
+	}
+	return sol, nil
+}
+
+func decodeShape(s *sceneShape) (Painter.Shape, error) {
+	if s == nil {
+		return nil, nil
+	}
+	switch s.Type {
+	case "t":
+		return Painter.TShape{Size: s.Size}, nil
+	case "circle":
+		return Painter.Circle{R: s.R}, nil
+	case "ellipse":
+		return Painter.Ellipse{RX: s.RX, RY: s.RY}, nil
+	case "polyline":
+		return Painter.Polyline{Points: decodePoints(s.Points), Width: s.Width}, nil
+	case "polygon":
+		return Painter.Polygon{Points: decodePoints(s.Points)}, nil
+	default:
+		return nil, fmt.Errorf("unknown shape type %q", s.Type)
+	}
+}
+
+func decodeStyle(c, blend string) (color.Color, Painter.BlendMode, error) {
+	var (
+		res  color.Color
+		mode Painter.BlendMode
+		err  error
+	)
+	if c != "" {
+		if res, err = parseColor(c); err != nil {
+			return nil, mode, err
+		}
+	}
+	if blend != "" {
+		var ok bool
+		if mode, ok = Painter.ParseBlendMode(blend); !ok {
+			return nil, mode, fmt.Errorf("unknown blend mode %q", blend)
+		}
+	}
+	return res, mode, nil
+}
+
+func encodeColor(c color.Color) string {
+	if c == nil {
+		return ""
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x%02x", n.R, n.G, n.B, n.A)
+}
+
+func encodePoint(p Painter.RelativePoint) scenePoint {
+	return scenePoint{p.X, p.Y}
+}
+
+func decodePoint(p scenePoint) Painter.RelativePoint {
+	return Painter.RelativePoint{X: p[0], Y: p[1]}
+}
+
+func encodePoints(points []Painter.RelativePoint) []scenePoint {
+	res := make([]scenePoint, len(points))
+	for i, p := range points {
+		res[i] = encodePoint(p)
+	}
+	return res
+}
+
+func decodePoints(points []scenePoint) []Painter.RelativePoint {
+	res := make([]Painter.RelativePoint, len(points))
+	for i, p := range points {
+		res[i] = decodePoint(p)
+	}
+	return res
+}
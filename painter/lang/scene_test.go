@@ -0,0 +1,82 @@
+package Lang
+
+import (
+	"encoding/json"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	Painter "github.com/roman-mazur/architecture-lab-3/painter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParser_SaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	reg := &ImageRegistry{}
+	reg.Add("logo", image.NewRGBA(image.Rect(0, 0, 2, 2)))
+	p := &Parser{ScenesDir: dir, Images: reg}
+
+	script := "green\nbgrect 0 0 0.5 0.5 red\nblend multiply\nfigure a 0.5 0.5\ncircle c 0.2 0.2 0.1 #00ff0080\n" +
+		"line 0 0 1 1\npolygon 0 0 1 0 1 1\nimage logo 0.1 0.1 0.2 0.2\ntext 0.1 0.9 0.05 black 'Hello'\nsave demo"
+	ops, err := p.Parse(strings.NewReader(script))
+	assert.Nil(t, err)
+	saved := ops[len(ops)-1].(*Painter.StatefulOperationList).Clone()
+
+	data, err := os.ReadFile(filepath.Join(dir, "demo.json"))
+	assert.Nil(t, err)
+	var raw map[string]any
+	assert.Nil(t, json.Unmarshal(data, &raw))
+	assert.Equal(t, float64(SceneVersion), raw["version"])
+	assert.Equal(t, "#00ff00ff", raw["background"])
+
+	ops, err = p.Parse(strings.NewReader("reset\nload demo"))
+	assert.Nil(t, err)
+	loaded := ops[len(ops)-1].(*Painter.StatefulOperationList)
+
+	// Кольори зберігаються у форматі #RRGGBBAA, тож порівнюємо їх значення, а не типи.
+	tx1, tx2 := Painter.NewSoftTexture(image.Pt(50, 50)), Painter.NewSoftTexture(image.Pt(50, 50))
+	saved.Do(tx1)
+	loaded.Do(tx2)
+	assert.Equal(t, tx1.RGBA().Pix, tx2.RGBA().Pix)
+
+	if assert.Len(t, loaded.FigureOperations, 4) {
+		assert.Equal(t, Painter.BlendMultiply, loaded.Figure("a").Blend)
+		assert.Nil(t, loaded.Figure("a").Shape)
+		assert.Equal(t, Painter.Circle{R: 0.1}, loaded.Figure("c").Shape)
+		assert.Equal(t, saved.FigureOperations[2].Shape, loaded.FigureOperations[2].Shape)
+	}
+	assert.Equal(t, "Hello", loaded.TextOperations[0].Text)
+
+	// Завантаження сцени можна скасувати.
+	ops, err = p.Parse(strings.NewReader("undo"))
+	assert.Nil(t, err)
+	assert.Empty(t, ops[0].(*Painter.StatefulOperationList).FigureOperations)
+}
+
+func TestParser_SaveLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{"version": 1, "figures": [{"id": "1"}]}`), 0o644))
+
+	p := &Parser{ScenesDir: dir}
+	_, err := p.Parse(strings.NewReader("load missing\nload broken\nsave ../x\nsave"))
+	var errs ParseErrors
+	if assert.ErrorAs(t, err, &errs) && assert.Len(t, errs, 4) {
+		assert.EqualError(t, errs[0].Err, "unknown scene")
+		assert.ErrorContains(t, errs[1].Err, "invalid scene")
+		assert.EqualError(t, errs[2].Err, "invalid scene name")
+		assert.IsType(t, countError{}, errs[3].Err)
+	}
+
+	// Сцена не записується, якщо в скрипті є помилка.
+	_, err = p.Parse(strings.NewReader("figure 0.5 0.5\nsave demo\nbogus"))
+	assert.Error(t, err)
+	_, err = os.Stat(filepath.Join(dir, "demo.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 1)
+
+	_, err = (&Parser{}).Parse(strings.NewReader("save demo"))
+	assert.ErrorContains(t, err, "scenes are not configured")
+}
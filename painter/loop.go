@@ -1,8 +1,13 @@
 package Painter
 
 import (
+	"context"
+	"errors"
 	"image"
+	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/exp/shiny/screen"
 )
@@ -12,89 +17,525 @@ type Receiver interface {
 	Update(t screen.Texture)
 }
 
+// ReceiverFunc використовується для перетворення функції в Receiver.
+type ReceiverFunc func(t screen.Texture)
+
+func (f ReceiverFunc) Update(t screen.Texture) {
+	f(t)
+}
+
 // Loop реалізує цикл подій для формування текстури отриманої через виконання операцій отриманих з внутрішньої черги.
+// Готова текстура передається у Receiver та у всі додаткові отримувачі, зареєстровані через AddReceiver. Кадри
+// доставляються в окремій горутині, тож повільний отримувач не блокує цикл: якщо він не встигає, проміжні кадри
+// пропускаються і доставляється найновіший.
 type Loop struct {
 	Receiver Receiver
+	// Size задає розмір текстур. Якщо розмір не вказано, використовується DefaultSize.
+	Size image.Point
+	// QueueCapacity обмежує кількість операцій у черзі. Якщо нуль, використовується DefaultQueueCapacity.
+	QueueCapacity int
+	// MaxFPS обмежує кількість кадрів за секунду. Якщо більше нуля, готові кадри передаються отримувачам лише
+	// на початку кожного інтервалу 1/MaxFPS, а кілька оновлень в межах одного інтервалу об'єднуються в один кадр.
+	// Якщо нуль, кожне оновлення передається одразу.
+	MaxFPS int
 
-	next screen.Texture // текстура, яка зараз формується
-	prev screen.Texture // текстура, яка була відправлення останнього разу у Receiver
+	screen screen.Screen
+	next   screen.Texture // текстура, яка зараз формується
+	prev   screen.Texture // текстура, яка була відправлення останнього разу у Receiver
 
 	mq messageQueue
 
 	stop    chan struct{}
-	stopReq bool
+	stopReq bool // змінюється лише операцією в горутині циклу, тож не потребує синхронізації
+
+	pending    atomic.Bool // prev містить кадр, який ще не передано отримувачам
+	tickQueued atomic.Bool // frameTick уже є в черзі
+	animating  atomic.Bool // є активні анімації
+
+	state *StatefulOperationList // останній виконаний стан, який буде показано з наступним UpdateOp
+	shown *StatefulOperationList // стан, показаний останнім UpdateOp, на основі якого малюються кадри анімацій
+	queue []AnimateOp            // анімації, які почнуться з наступним UpdateOp
+	stale bool                   // next містить уже опублікований кадр, а не результат останніх операцій
+	anim  animator
+
+	frames    chan []delivery // кадри для горутини доставки, не більше одного пакета
+	delivered chan struct{}   // закривається, коли горутина доставки завершилась
+
+	rmu       sync.Mutex
+	receivers []receiverEntry
+	nextID    int
+
+	stats struct {
+		executed, coalesced, published, dropped atomic.Uint64
+	}
+}
+
+// delivery кадр для одного отримувача.
+type delivery struct {
+	r Receiver
+	t screen.Texture
+}
+
+// LoopStats лічильники роботи циклу з моменту запуску.
+type LoopStats struct {
+	Executed  uint64 `json:"executed"`  // Кількість виконаних операцій.
+	Coalesced uint64 `json:"coalesced"` // Кількість пропущених перемальовувань стану, за якими йшов новіший стан.
+	Published uint64 `json:"published"` // Кількість кадрів, переданих отримувачам.
+	Dropped   uint64 `json:"dropped"`   // Кількість кадрів, замінених новішими, поки отримувачі були зайняті.
+}
+
+// Stats повертає поточні значення лічильників. Безпечний для виклику з будь-якої горутини.
+func (l *Loop) Stats() LoopStats {
+	return LoopStats{
+		Executed:  l.stats.executed.Load(),
+		Coalesced: l.stats.coalesced.Load(),
+		Published: l.stats.published.Load(),
+		Dropped:   l.stats.dropped.Load(),
+	}
+}
+
+// redrawsAll повідомляє, чи перемальовує операція всю текстуру незалежно від її попереднього вмісту.
+func redrawsAll(op Operation) bool {
+	switch op.(type) {
+	case *StatefulOperationList, StatefulOperationList:
+		return true
+	}
+	return false
+}
+
+type receiverEntry struct {
+	id int
+	r  Receiver
+}
+
+// DefaultSize розмір текстур Loop за замовчуванням.
+var DefaultSize = image.Pt(400, 400)
+
+// DefaultQueueCapacity місткість черги Loop за замовчуванням.
+const DefaultQueueCapacity = 4096
+
+var (
+	// ErrQueueFull повертається, коли в черзі циклу немає місця для операцій.
+	ErrQueueFull = errors.New("loop queue is full")
+	// ErrLoopStopped повертається, коли операцію відправлено в цикл, який зупиняється.
+	ErrLoopStopped = errors.New("loop is stopped")
+	// ErrTooManyOperations повертається, коли операцій більше, ніж місткість черги, тож вони ніколи не вмістяться.
+	ErrTooManyOperations = errors.New("too many operations for the loop queue")
+)
+
+// LoopOption налаштовує Loop, створений через NewLoop.
+type LoopOption func(l *Loop)
+
+// WithSize задає розмір текстур циклу.
+func WithSize(size image.Point) LoopOption {
+	return func(l *Loop) {
+		l.Size = size
+	}
+}
+
+// WithMaxFPS обмежує кількість кадрів за секунду (див. Loop.MaxFPS).
+func WithMaxFPS(fps int) LoopOption {
+	return func(l *Loop) {
+		l.MaxFPS = fps
+	}
+}
+
+// WithQueueCapacity задає місткість черги операцій.
+func WithQueueCapacity(n int) LoopOption {
+	return func(l *Loop) {
+		l.QueueCapacity = n
+	}
 }
 
-var size = image.Pt(400, 400)
+// NewLoop створює цикл подій з вказаними налаштуваннями. Нульове значення Loop також готове до використання.
+func NewLoop(opts ...LoopOption) *Loop {
+	l := &Loop{}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
 
 // Start запускає цикл подій. Цей метод потрібно запустити до того, як викликати на ньому будь-які інші методи.
 func (l *Loop) Start(s screen.Screen) {
+	size := l.Size
+	if size == (image.Point{}) {
+		size = DefaultSize
+	}
+	l.screen = s
 	l.next, _ = s.NewTexture(size)
 	l.prev, _ = s.NewTexture(size)
 	l.stop = make(chan struct{})
+	l.frames = make(chan []delivery, 1)
+	l.delivered = make(chan struct{})
+
+	go l.deliver()
+	fps := l.MaxFPS
+	if fps <= 0 {
+		fps = DefaultAnimationFPS
+	}
+	go l.tick(time.Second / time.Duration(fps))
 
 	go func() {
 		for !l.stopReq || !l.mq.empty() {
 			op := l.mq.pull()
-			update := op.Do(l.next)
-			if update {
-				l.Receiver.Update(l.next)
-				l.next, l.prev = l.prev, l.next
+			if r, ok := op.(ResizeOp); ok {
+				l.resize(r.Size)
+				continue
+			}
+			if _, ok := op.(frameTick); ok {
+				l.tickQueued.Store(false)
+				// Кадри анімацій не показують змін, для яких ще не було UpdateOp.
+				if l.anim.active() && l.shown != nil {
+					l.draw(l.shown)
+					l.update()
+				}
+				if l.pending.Swap(false) {
+					l.publish(l.prev)
+				}
+				continue
+			}
+			if a, ok := op.(AnimateOp); ok {
+				l.animate(a)
+				continue
+			}
+			// Наступна операція однаково перемалює всю текстуру, тож поточну можна не виконувати.
+			if redrawsAll(op) && l.mq.nextRedrawsAll() {
+				l.stats.coalesced.Add(1)
+				continue
+			}
+			l.stats.executed.Add(1)
+			if st, ok := op.(*StatefulOperationList); ok {
+				l.state = st
+				l.draw(st)
+				continue
+			}
+			if op == UpdateOp && l.state != nil && (l.startAnimations() || l.stale) {
+				// Після кадру анімації next застарів, а щойно запущені анімації змінюють положення фігур, тож
+				// спочатку малюємо в ньому поточний стан.
+				l.draw(l.state)
+			}
+			l.stale = false
+			if op.Do(l.next) {
+				if op == UpdateOp {
+					l.shown = l.state
+				}
+				l.update()
 			}
 		}
+		if l.pending.Swap(false) {
+			l.publish(l.prev)
+		}
+		close(l.frames)
+		<-l.delivered
 		close(l.stop)
 	}()
 }
 
-// Post додає нову операцію у внутрішню чергу.
+// update робить текстуру next готовим кадром. Якщо задано MaxFPS, кадр чекає на наступний інтервал у prev, а якщо
+// до того прийде ще одне оновлення, новіший кадр замінить його.
+func (l *Loop) update() {
+	if l.MaxFPS > 0 {
+		l.next, l.prev = l.prev, l.next
+		l.pending.Store(true)
+	} else {
+		l.publish(l.next)
+		l.next, l.prev = l.prev, l.next
+	}
+	l.stale = true
+}
+
+// draw малює стан у next, переносячи фігури, які анімуються, у їхні поточні положення.
+func (l *Loop) draw(st *StatefulOperationList) {
+	if st == nil {
+		return
+	}
+	l.anim.apply(st, time.Now()).Do(l.next)
+	l.stale = false
+	l.animating.Store(l.anim.active())
+}
+
+// animate малює стан з AnimateOp, як і для інших станів, а анімації відкладає до UpdateOp.
+func (l *Loop) animate(op AnimateOp) {
+	l.stats.executed.Add(1)
+	l.queue = append(l.queue, op)
+	l.state = op.State
+	l.draw(op.State)
+}
+
+// startAnimations запускає анімації, які чекали на UpdateOp, та скасовує анімації фігур, які після них змінили
+// інші команди. Повертає true, якщо next потрібно перемалювати з новими анімаціями.
+func (l *Loop) startAnimations() bool {
+	now := time.Now()
+	for _, op := range l.queue {
+		for _, id := range op.Cancel {
+			l.anim.cancel(id)
+		}
+		for _, a := range op.Animations {
+			l.anim.add(a, now)
+		}
+	}
+	changed := len(l.queue) > 0
+	l.queue = nil
+	if l.anim.prune(l.state) {
+		changed = true
+	}
+	return changed || l.anim.active()
+}
+
+// frameTick сигналізує горутині циклу, що почався новий інтервал кадру.
+type frameTick struct{}
+
+func (frameTick) Do(screen.Texture) bool { return false }
+
+// tick щоінтервалу додає в чергу frameTick, якщо є кадр, який очікує на доставку, або активні анімації. Якщо
+// черга заповнена, спроба повторюється на наступному інтервалі.
+func (l *Loop) tick(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			if (l.pending.Load() || l.animating.Load()) && !l.tickQueued.Swap(true) {
+				if err := l.TryPost(frameTick{}); err != nil {
+					l.tickQueued.Store(false)
+				}
+			}
+		}
+	}
+}
+
+// deliver передає кадри отримувачам, поки не буде закрито канал frames.
+func (l *Loop) deliver() {
+	defer close(l.delivered)
+	for batch := range l.frames {
+		for _, d := range batch {
+			d.r.Update(d.t)
+		}
+	}
+}
+
+// resize замінює текстури циклу на нові вказаного розміру. Викликається лише з горутини циклу, тож жодна операція
+// не малює в текстури під час заміни. Нові текстури порожні: поточний стан з'явиться в них з наступним UpdateOp, а
+// операції без стану потрібно виконати заново.
+func (l *Loop) resize(size image.Point) {
+	next, err := l.screen.NewTexture(size)
+	if err != nil {
+		log.Printf("Failed to resize textures: %s", err)
+		return
+	}
+	prev, err := l.screen.NewTexture(size)
+	if err != nil {
+		next.Release()
+		log.Printf("Failed to resize textures: %s", err)
+		return
+	}
+	l.next.Release()
+	l.prev.Release()
+	l.next, l.prev = next, prev
+	// Кадр, який чекав на свій інтервал, малюється заново в новому розмірі.
+	if l.pending.Load() {
+		if l.shown != nil {
+			l.draw(l.shown)
+			l.next, l.prev = l.prev, l.next
+		} else {
+			l.pending.Store(false)
+		}
+	}
+	// next порожній, тож UpdateOp спочатку намалює в ньому поточний стан.
+	l.stale = true
+}
+
+// AddReceiver реєструє додатковий Receiver, який отримуватиме кожну готову текстуру. Його можна додати як до, так і
+// після запуску циклу. Повертає функцію, яка скасовує реєстрацію.
+func (l *Loop) AddReceiver(r Receiver) (remove func()) {
+	l.rmu.Lock()
+	defer l.rmu.Unlock()
+
+	l.nextID++
+	id := l.nextID
+	l.receivers = append(l.receivers, receiverEntry{id: id, r: r})
+
+	return func() {
+		l.rmu.Lock()
+		defer l.rmu.Unlock()
+		for i, e := range l.receivers {
+			if e.id == id {
+				l.receivers = append(l.receivers[:i:i], l.receivers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// publish передає готову текстуру всім отримувачам через горутину доставки. Текстури, які зберігаються в пам'яті,
+// копіюються для кожного отримувача окремо, тож подальше малювання у next/prev не змінює вже відправлені кадри.
+// Якщо попередній кадр ще не доставлено, він замінюється новим.
+func (l *Loop) publish(t screen.Texture) {
+	l.rmu.Lock()
+	receivers := make([]Receiver, 0, len(l.receivers)+1)
+	if l.Receiver != nil {
+		receivers = append(receivers, l.Receiver)
+	}
+	for _, e := range l.receivers {
+		receivers = append(receivers, e.r)
+	}
+	l.rmu.Unlock()
+
+	batch := make([]delivery, len(receivers))
+	for i, r := range receivers {
+		batch[i] = delivery{r: r, t: copyTexture(t)}
+	}
+	l.stats.published.Add(1)
+	// Цикл єдиний, хто надсилає в frames, тож після того, як старий кадр забрано, місце точно є.
+	select {
+	case l.frames <- batch:
+	default:
+		select {
+		case <-l.frames:
+			l.stats.dropped.Add(1)
+		default:
+		}
+		l.frames <- batch
+	}
+}
+
+// Post додає нову операцію у внутрішню чергу. Якщо черга заповнена, Post чекає, поки в ній з'явиться місце.
+// Операції, відправлені після StopAndWait, відкидаються.
 func (l *Loop) Post(op Operation) {
-	l.mq.push(op)
+	_ = l.PostContext(context.Background(), op)
+}
+
+// PostContext додає операцію в чергу, чекаючи на місце в ній, доки не завершиться ctx. Повертає помилку ctx,
+// якщо місце так і не з'явилося, або ErrLoopStopped, якщо цикл зупиняється.
+func (l *Loop) PostContext(ctx context.Context, op Operation) error {
+	return l.mq.push(ctx, l.capacity(), true, op)
+}
+
+// TryPost додає в чергу всі операції або жодної, не блокуючись. Повертає ErrQueueFull, якщо для всіх операцій
+// немає місця, ErrTooManyOperations, якщо операцій більше за місткість черги, або ErrLoopStopped, якщо цикл
+// зупиняється.
+func (l *Loop) TryPost(ops ...Operation) error {
+	return l.mq.push(context.Background(), l.capacity(), false, ops...)
+}
+
+func (l *Loop) capacity() int {
+	if l.QueueCapacity > 0 {
+		return l.QueueCapacity
+	}
+	return DefaultQueueCapacity
 }
 
-// StopAndWait сигналізує про необхідність завершити цикл та блокується до моменту його повної зупинки.
+// StopAndWait сигналізує про необхідність завершити цикл та блокується до моменту його повної зупинки. Операції,
+// які вже є в черзі, буде виконано, а нові операції не приймаються.
 func (l *Loop) StopAndWait() {
-	l.Post(OperationFunc(func(t screen.Texture) {
+	l.mq.close(OperationFunc(func(t screen.Texture) {
 		l.stopReq = true
 	}))
 	<-l.stop
 }
 
+// messageQueue черга операцій з обмеженою місткістю. Нульове значення готове до використання.
 type messageQueue struct {
-	operations []Operation
 	mu         sync.Mutex
-	blocked    chan struct{}
+	notEmpty   *sync.Cond
+	notFull    chan struct{} // закривається, коли з черги забирають операцію
+	operations []Operation
+	closed     bool
 }
 
-func (mq *messageQueue) push(op Operation) {
+// init створює умовну змінну при першому використанні черги. Потребує захоплення mu.
+func (mq *messageQueue) init() {
+	if mq.notEmpty == nil {
+		mq.notEmpty = sync.NewCond(&mq.mu)
+	}
+}
+
+// push додає всі операції або жодної. Якщо місця для них немає, то при wait очікує, поки воно з'явиться або
+// завершиться ctx, а інакше одразу повертає ErrQueueFull.
+func (mq *messageQueue) push(ctx context.Context, capacity int, wait bool, ops ...Operation) error {
 	mq.mu.Lock()
 	defer mq.mu.Unlock()
+	mq.init()
 
-	mq.operations = append(mq.operations, op)
-
-	if mq.blocked != nil {
-		close(mq.blocked)
-		mq.blocked = nil
+	for {
+		if mq.closed {
+			return ErrLoopStopped
+		}
+		if len(ops) > capacity {
+			return ErrTooManyOperations
+		}
+		if len(mq.operations)+len(ops) <= capacity {
+			break
+		}
+		if !wait {
+			return ErrQueueFull
+		}
+		if mq.notFull == nil {
+			mq.notFull = make(chan struct{})
+		}
+		notFull := mq.notFull
+		mq.mu.Unlock()
+		select {
+		case <-notFull:
+			mq.mu.Lock()
+		case <-ctx.Done():
+			mq.mu.Lock()
+			return ctx.Err()
+		}
 	}
+	mq.operations = append(mq.operations, ops...)
+	mq.notEmpty.Signal()
+	return nil
 }
 
+// close додає останню операцію незалежно від місткості, після чого черга не приймає нових операцій.
+func (mq *messageQueue) close(op Operation) {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	mq.init()
+
+	mq.operations = append(mq.operations, op)
+	mq.closed = true
+	mq.notEmpty.Signal()
+	mq.wakeWriters()
+}
+
+// pull повертає першу операцію з черги, блокуючись, поки черга порожня.
 func (mq *messageQueue) pull() Operation {
 	mq.mu.Lock()
 	defer mq.mu.Unlock()
+	mq.init()
 
-	if len(mq.operations) == 0 {
-		mq.blocked = make(chan struct{})
-		mq.mu.Unlock()
-		<-mq.blocked
-		mq.blocked = nil
-		mq.mu.Lock()
+	for len(mq.operations) == 0 {
+		mq.notEmpty.Wait()
 	}
-
 	op := mq.operations[0]
+	mq.operations[0] = nil
 	mq.operations = mq.operations[1:]
-
+	mq.wakeWriters()
 	return op
 }
 
+// wakeWriters будить усіх, хто чекає на місце в черзі. Потребує захоплення mu.
+func (mq *messageQueue) wakeWriters() {
+	if mq.notFull != nil {
+		close(mq.notFull)
+		mq.notFull = nil
+	}
+}
+
+// nextRedrawsAll повідомляє, чи перемальовує перша операція в черзі всю текстуру (див. redrawsAll).
+func (mq *messageQueue) nextRedrawsAll() bool {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	return len(mq.operations) > 0 && redrawsAll(mq.operations[0])
+}
+
 func (mq *messageQueue) empty() bool {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
 	return len(mq.operations) == 0
 }
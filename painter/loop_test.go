@@ -1,6 +1,7 @@
 package Painter
 
 import (
+	"context"
 	"image"
 	"image/color"
 	"image/draw"
@@ -78,10 +79,10 @@ func TestLoop_Post_Successful(t *testing.T) {
 	textureMock.On("Bounds").Return(image.Rectangle{})
 	operationOne.On("Do", textureMock).Return(true)
 
-	assert.Empty(t, loop.mq.operations)
+	assert.True(t, loop.mq.empty())
 	loop.Post(operationOne)
 	time.Sleep(1 * time.Second)
-	assert.Empty(t, loop.mq.operations)
+	assert.True(t, loop.mq.empty())
 
 	operationOne.AssertCalled(t, "Do", textureMock)
 	receiverMock.AssertCalled(t, "Update", textureMock)
@@ -106,10 +107,10 @@ func TestLoop_Post_Failed(t *testing.T) {
 	textureMock.On("Bounds").Return(image.Rectangle{})
 	operationOne.On("Do", textureMock).Return(false)
 
-	assert.Empty(t, loop.mq.operations)
+	assert.True(t, loop.mq.empty())
 	loop.Post(operationOne)
 	time.Sleep(1 * time.Second)
-	assert.Empty(t, loop.mq.operations)
+	assert.True(t, loop.mq.empty())
 
 	operationOne.AssertCalled(t, "Do", textureMock)
 	receiverMock.AssertNotCalled(t, "Update", textureMock)
@@ -136,14 +137,190 @@ func TestLoop_Post_Multiple(t *testing.T) {
 	operationOne.On("Do", textureMock).Return(true)
 	operationTwo.On("Do", textureMock).Return(true)
 
-	assert.Empty(t, loop.mq.operations)
+	assert.True(t, loop.mq.empty())
 	loop.Post(operationOne)
 	loop.Post(operationTwo)
 	time.Sleep(1 * time.Second)
-	assert.Empty(t, loop.mq.operations)
+	assert.True(t, loop.mq.empty())
 
 	operationOne.AssertCalled(t, "Do", textureMock)
 	operationTwo.AssertCalled(t, "Do", textureMock)
 	receiverMock.AssertCalled(t, "Update", textureMock)
 	screenMock.AssertCalled(t, "NewTexture", image.Pt(400, 400))
 }
+
+func TestLoop_AddReceiver(t *testing.T) {
+	first := make(chan screen.Texture, 2)
+	second := make(chan screen.Texture, 2)
+	loop := Loop{}
+	loop.AddReceiver(ReceiverFunc(func(t screen.Texture) { first <- t }))
+	removeSecond := loop.AddReceiver(ReceiverFunc(func(t screen.Texture) { second <- t }))
+
+	loop.Start(SoftScreen{})
+	loop.Post(OperationFill{Color: color.White})
+	loop.Post(UpdateOp)
+
+	a, b := <-first, <-second
+	assert.NotSame(t, a, b)
+	assert.Equal(t, a.(*SoftTexture).RGBA().Pix, b.(*SoftTexture).RGBA().Pix)
+
+	removeSecond()
+	loop.Post(UpdateOp)
+	<-first
+	loop.StopAndWait()
+	assert.Empty(t, second)
+}
+
+func TestLoop_Resize(t *testing.T) {
+	frames := make(chan screen.Texture, 2)
+	loop := NewLoop(WithSize(image.Pt(100, 50)))
+	loop.Receiver = ReceiverFunc(func(t screen.Texture) { frames <- t })
+	loop.Start(SoftScreen{})
+
+	loop.Post(UpdateOp)
+	assert.Equal(t, image.Pt(100, 50), (<-frames).Size())
+
+	loop.Post(ResizeOp{Size: image.Pt(30, 20)})
+	loop.Post(OperationFill{Color: color.White})
+	loop.Post(UpdateOp)
+	frame := <-frames
+	loop.StopAndWait()
+
+	assert.Equal(t, image.Pt(30, 20), frame.Size())
+	assert.Equal(t, color.RGBAModel.Convert(color.White), frame.(*SoftTexture).RGBA().At(29, 19))
+}
+
+func TestLoop_ResizeState(t *testing.T) {
+	red := color.RGBA{R: 0xff, A: 0xff}
+	state := &StatefulOperationList{BgOperation: OperationFill{Color: red}}
+
+	for _, fps := range []int{0, 10} {
+		frames := make(chan screen.Texture, 2)
+		loop := NewLoop(WithSize(image.Pt(100, 50)), WithMaxFPS(fps))
+		loop.Receiver = ReceiverFunc(func(t screen.Texture) { frames <- t })
+		loop.Start(SoftScreen{})
+
+		// Стан, намальований до зміни розміру, показується після неї.
+		loop.Post(state)
+		loop.Post(ResizeOp{Size: image.Pt(30, 20)})
+		loop.Post(UpdateOp)
+		frame := <-frames
+		assert.Equal(t, image.Pt(30, 20), frame.Size())
+		assert.Equal(t, red, frame.(*SoftTexture).RGBA().RGBAAt(29, 19))
+
+		// Кадр, який чекає на свій інтервал, не губиться через зміну розміру.
+		loop.Post(UpdateOp)
+		loop.Post(ResizeOp{Size: image.Pt(40, 10)})
+		loop.StopAndWait()
+		if fps > 0 {
+			frame = <-frames
+			assert.Equal(t, image.Pt(40, 10), frame.Size())
+			assert.Equal(t, red, frame.(*SoftTexture).RGBA().RGBAAt(39, 9))
+		}
+	}
+}
+
+func TestLoop_QueueCapacity(t *testing.T) {
+	loop := NewLoop(WithQueueCapacity(2))
+	assert.Nil(t, loop.TryPost(UpdateOp))
+	assert.ErrorIs(t, loop.TryPost(UpdateOp, UpdateOp), ErrQueueFull)
+	assert.ErrorIs(t, loop.TryPost(UpdateOp, UpdateOp, UpdateOp), ErrTooManyOperations)
+	assert.Nil(t, loop.TryPost(UpdateOp))
+	assert.ErrorIs(t, loop.TryPost(UpdateOp), ErrQueueFull)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, loop.PostContext(ctx, UpdateOp), context.DeadlineExceeded)
+
+	// Після запуску цикл звільняє чергу, і заблокований виклик завершується.
+	posted := make(chan error)
+	go func() { posted <- loop.PostContext(context.Background(), OperationFill{Color: color.White}) }()
+	loop.Start(SoftScreen{})
+	assert.Nil(t, <-posted)
+
+	loop.StopAndWait()
+	assert.ErrorIs(t, loop.TryPost(UpdateOp), ErrLoopStopped)
+	assert.ErrorIs(t, loop.PostContext(context.Background(), UpdateOp), ErrLoopStopped)
+}
+
+func TestLoop_Coalescing(t *testing.T) {
+	frames := make(chan screen.Texture, 2)
+	loop := NewLoop(WithSize(image.Pt(10, 10)))
+	loop.Receiver = ReceiverFunc(func(t screen.Texture) { frames <- t })
+
+	// Операції відправляються до запуску, тож на момент виконання вони всі вже в черзі.
+	for _, c := range []color.RGBA{{R: 0xff, A: 0xff}, {G: 0xff, A: 0xff}, {B: 0xff, A: 0xff}} {
+		loop.Post(&StatefulOperationList{BgOperation: OperationFill{Color: c}})
+	}
+	loop.Post(UpdateOp)
+	loop.Post(&StatefulOperationList{})
+	loop.Post(UpdateOp)
+	loop.Start(SoftScreen{})
+	loop.StopAndWait()
+
+	// Перший кадр може бути замінений другим, якщо отримувач не встиг його забрати.
+	stats := loop.Stats()
+	assert.Equal(t, LoopStats{Executed: 5, Coalesced: 2, Published: 2, Dropped: stats.Dropped}, stats)
+	if assert.Len(t, frames, int(2-stats.Dropped)) && stats.Dropped == 0 {
+		assert.Equal(t, color.RGBA{B: 0xff, A: 0xff}, (<-frames).(*SoftTexture).RGBA().RGBAAt(5, 5))
+	}
+	var last screen.Texture
+	for len(frames) > 0 {
+		last = <-frames
+	}
+	assert.Equal(t, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, last.(*SoftTexture).RGBA().RGBAAt(5, 5))
+}
+
+func TestLoop_MaxFPS(t *testing.T) {
+	frames := make(chan screen.Texture, 10)
+	loop := NewLoop(WithSize(image.Pt(10, 10)), WithMaxFPS(10))
+	loop.Receiver = ReceiverFunc(func(t screen.Texture) { frames <- t })
+
+	// Оновлення в межах одного інтервалу об'єднуються в один кадр з останнім станом.
+	for _, c := range []color.RGBA{{R: 0xff, A: 0xff}, {G: 0xff, A: 0xff}, {B: 0xff, A: 0xff}} {
+		loop.Post(&StatefulOperationList{BgOperation: OperationFill{Color: c}})
+		loop.Post(UpdateOp)
+	}
+	start := time.Now()
+	loop.Start(SoftScreen{})
+	frame := <-frames
+	assert.Equal(t, color.RGBA{B: 0xff, A: 0xff}, frame.(*SoftTexture).RGBA().RGBAAt(5, 5))
+	assert.Less(t, time.Since(start), time.Second)
+
+	loop.Post(OperationFill{Color: color.White})
+	loop.Post(UpdateOp)
+	loop.StopAndWait()
+	// Кадр, який очікував на інтервал, доставляється під час зупинки.
+	assert.Len(t, frames, 1)
+	assert.Equal(t, uint64(2), loop.Stats().Published)
+}
+
+func TestLoop_SlowReceiver(t *testing.T) {
+	release := make(chan struct{})
+	frames := make(chan screen.Texture, 10)
+	loop := NewLoop(WithSize(image.Pt(10, 10)))
+	loop.Receiver = ReceiverFunc(func(t screen.Texture) {
+		<-release
+		frames <- t
+	})
+	loop.Start(SoftScreen{})
+
+	// Отримувач заблокований, але цикл продовжує обробляти операції.
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, loop.PostContext(context.Background(), UpdateOp))
+	}
+	done := make(chan struct{})
+	loop.Post(OperationFunc(func(screen.Texture) { close(done) }))
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("loop is blocked by the receiver")
+	}
+
+	close(release)
+	loop.StopAndWait()
+	stats := loop.Stats()
+	assert.Equal(t, uint64(5), stats.Published)
+	assert.Equal(t, int(stats.Published-stats.Dropped), len(frames))
+	assert.Greater(t, stats.Dropped, uint64(0))
+}
@@ -3,7 +3,7 @@ package Painter
 import (
 	"image"
 	"image/color"
-	"image/draw"
+	"strconv"
 
 	"golang.org/x/exp/shiny/screen"
 )
@@ -20,9 +20,15 @@ type StateTweaker interface {
 
 // StatefulOperationList утримує список операцій, які змінюють стан.
 type StatefulOperationList struct {
-	BgOperation      Operation
-	BgRectOperation  Operation
+	BgOperation Operation
+	// Прямокутники малюються в порядку списку: кожен наступний перекриває попередні.
+	BgRectOperations []OperationBGRect
 	FigureOperations []*OperationFigure
+	// Зображення малюються поверх фігур, а текст поверх зображень.
+	ImageOperations []OperationImage
+	TextOperations  []OperationText
+
+	figureSeq int // лічильник для автоматичних ідентифікаторів фігур
 }
 
 // Do виконує всі операції в списку.
@@ -32,12 +38,18 @@ func (sol StatefulOperationList) Do(t screen.Texture) bool {
 	} else {
 		defaultFill(t, color.White)
 	}
-	if sol.BgRectOperation != nil {
-		sol.BgRectOperation.Do(t)
+	for _, op := range sol.BgRectOperations {
+		op.Do(t)
 	}
 	for _, op := range sol.FigureOperations {
 		op.Do(t)
 	}
+	for _, op := range sol.ImageOperations {
+		op.Do(t)
+	}
+	for _, op := range sol.TextOperations {
+		op.Do(t)
+	}
 	return false
 }
 
@@ -46,6 +58,41 @@ func (sol *StatefulOperationList) Update(tweaker StateTweaker) {
 	tweaker.SetState(sol)
 }
 
+// Clone повертає копію стану, зміни якої не впливають на оригінал.
+func (sol *StatefulOperationList) Clone() StatefulOperationList {
+	res := *sol
+	res.BgRectOperations = append([]OperationBGRect(nil), sol.BgRectOperations...)
+	res.FigureOperations = make([]*OperationFigure, len(sol.FigureOperations))
+	for i, f := range sol.FigureOperations {
+		fc := *f
+		res.FigureOperations[i] = &fc
+	}
+	res.ImageOperations = append([]OperationImage(nil), sol.ImageOperations...)
+	res.TextOperations = append([]OperationText(nil), sol.TextOperations...)
+	return res
+}
+
+// Figure повертає фігуру з вказаним ідентифікатором або nil, якщо такої немає.
+func (sol *StatefulOperationList) Figure(id string) *OperationFigure {
+	for _, f := range sol.FigureOperations {
+		if f.ID == id {
+			return f
+		}
+	}
+	return nil
+}
+
+// newFigureID генерує ідентифікатор виду f1, f2, ..., який ще не використовується жодною фігурою.
+func (sol *StatefulOperationList) newFigureID() string {
+	for {
+		sol.figureSeq++
+		id := "f" + strconv.Itoa(sol.figureSeq)
+		if sol.Figure(id) == nil {
+			return id
+		}
+	}
+}
+
 // defaultFill зафарбовує текстуру у вказаний колір.
 func defaultFill(t screen.Texture, c color.Color) {
 	t.Fill(t.Bounds(), c, screen.Src)
@@ -58,6 +105,14 @@ type updateOp struct{}
 
 func (op updateOp) Do(screen.Texture) bool { return true }
 
+// ResizeOp змінює розмір текстур циклу подій. Loop виконує її сам, замінюючи текстури між операціями, тому метод Do
+// нічого не робить.
+type ResizeOp struct {
+	Size image.Point
+}
+
+func (op ResizeOp) Do(screen.Texture) bool { return false }
+
 // OperationFunc використовується для перетворення функції оновлення текстури в Operation.
 type OperationFunc func(t screen.Texture)
 
@@ -89,9 +144,17 @@ func (p RelativePoint) ToAbs(size image.Point) image.Point {
 	return image.Point{X: int(p.X * float64(size.X)), Y: int(p.Y * float64(size.Y))}
 }
 
-// OperationBGRect зафарбовує прямокутну область текстури.
+// Clamp обмежує координати точки межами полотна [0, 1].
+func (p RelativePoint) Clamp() RelativePoint {
+	return RelativePoint{X: min(max(p.X, 0), 1), Y: min(max(p.Y, 0), 1)}
+}
+
+// OperationBGRect зафарбовує прямокутну область текстури. Якщо колір не вказано, область зафарбовується чорним.
+// Blend визначає, як колір поєднується з уже намальованим.
 type OperationBGRect struct {
 	Min, Max RelativePoint
+	Color    color.Color
+	Blend    BlendMode
 }
 
 func (op OperationBGRect) Do(t screen.Texture) bool {
@@ -99,48 +162,134 @@ func (op OperationBGRect) Do(t screen.Texture) bool {
 		Min: op.Min.ToAbs(t.Size()),
 		Max: op.Max.ToAbs(t.Size()),
 	}
-	t.Fill(rect, color.Black, draw.Src)
+	c := op.Color
+	if c == nil {
+		c = color.Black
+	}
+	fillRect(t, rect, c, op.Blend)
 	return false
 }
 
 func (op OperationBGRect) SetState(sol *StatefulOperationList) {
-	sol.BgRectOperation = op
+	sol.BgRectOperations = append(sol.BgRectOperations, op)
 }
 
-// OperationFigure визначає операцію для фігури.
+// RemoveRectTweaker видаляє прямокутник з позицією Index у порядку малювання.
+type RemoveRectTweaker struct {
+	Index int
+}
+
+func (tweaker RemoveRectTweaker) SetState(sol *StatefulOperationList) {
+	if tweaker.Index < 0 || tweaker.Index >= len(sol.BgRectOperations) {
+		return
+	}
+	sol.BgRectOperations = append(sol.BgRectOperations[:tweaker.Index:tweaker.Index],
+		sol.BgRectOperations[tweaker.Index+1:]...)
+}
+
+// ClearRectsTweaker видаляє всі прямокутники.
+type ClearRectsTweaker struct{}
+
+func (tweaker ClearRectsTweaker) SetState(sol *StatefulOperationList) {
+	sol.BgRectOperations = nil
+}
+
+// ReorderRectTweaker переміщує прямокутник з позиції From на позицію To у порядку малювання. Позиція 0 відповідає
+// найнижчому прямокутнику.
+type ReorderRectTweaker struct {
+	From, To int
+}
+
+func (tweaker ReorderRectTweaker) SetState(sol *StatefulOperationList) {
+	rects := sol.BgRectOperations
+	if tweaker.From < 0 || tweaker.From >= len(rects) || tweaker.To < 0 || tweaker.To >= len(rects) {
+		return
+	}
+	rect := rects[tweaker.From]
+	rects = append(rects[:tweaker.From:tweaker.From], rects[tweaker.From+1:]...)
+	rects = append(rects[:tweaker.To], append([]OperationBGRect{rect}, rects[tweaker.To:]...)...)
+	sol.BgRectOperations = rects
+}
+
+// FigureColor колір фігури за замовчуванням.
+var FigureColor color.Color = color.RGBA{R: 0, G: 54, B: 206, A: 0xff}
+
+// OperationFigure визначає операцію для фігури. Якщо колір не вказано, використовується FigureColor, а якщо не
+// вказано форму, малюється TShape. ID унікально визначає фігуру в стані; якщо його не вказано, SetState призначає
+// фігурі новий ідентифікатор. Blend визначає, як колір фігури поєднується з уже намальованим.
 type OperationFigure struct {
+	ID     string
 	Center RelativePoint
+	Color  color.Color
+	Shape  Shape
+	Blend  BlendMode
 }
 
 func (op OperationFigure) Do(t screen.Texture) bool {
-	centerAbs := op.Center.ToAbs(t.Size())
-	drawT(t, centerAbs, 50, 40, color.RGBA{R: 0, G: 54, B: 206, A: 1})
+	size := t.Size()
+	center := Vertex{X: op.Center.X * float64(size.X), Y: op.Center.Y * float64(size.Y)}
+	c := op.Color
+	if c == nil {
+		c = FigureColor
+	}
+	shape := op.Shape
+	if shape == nil {
+		shape = TShape{}
+	}
+	fillContours(t, shape.Contours(center, size), c, op.Blend)
 	return false
 }
 
 func (op OperationFigure) SetState(sol *StatefulOperationList) {
+	if op.ID == "" {
+		op.ID = sol.newFigureID()
+	}
 	sol.FigureOperations = append(sol.FigureOperations, &op)
 }
 
-func drawT(t screen.Texture, center image.Point, hlen, hwidth int, c color.Color) {
-
-	topHorizontal := image.Rect(center.X-hlen, center.Y-hwidth, center.X+hlen, center.Y)
-	t.Fill(topHorizontal, c, draw.Src)
-
-	// Нижній вертикальний прямокутник
-	bottomVertical := image.Rect(center.X-hwidth/2, center.Y, center.X+hwidth/2, center.Y+hlen)
-	t.Fill(bottomVertical, c, draw.Src)
-}
-
-// MoveTweaker зміщує фігури.
+// MoveTweaker зміщує на Offset фігуру з ідентифікатором ID або всі фігури, якщо ID порожній. Центр фігури не може
+// вийти за межі полотна: координати обмежуються діапазоном [0, 1].
 type MoveTweaker struct {
+	ID     string
 	Offset RelativePoint
 }
 
 func (tweaker MoveTweaker) SetState(sol *StatefulOperationList) {
 	for _, i := range sol.FigureOperations {
-		i.Center.X = tweaker.Offset.X
-		i.Center.Y = tweaker.Offset.Y
+		if tweaker.ID != "" && i.ID != tweaker.ID {
+			continue
+		}
+		i.Center = RelativePoint{X: i.Center.X + tweaker.Offset.X, Y: i.Center.Y + tweaker.Offset.Y}.Clamp()
+	}
+}
+
+// MoveToTweaker переносить центр фігури з ідентифікатором ID (або всіх фігур, якщо ID порожній) у точку Position.
+// Координати обмежуються так само, як у MoveTweaker.
+type MoveToTweaker struct {
+	ID       string
+	Position RelativePoint
+}
+
+func (tweaker MoveToTweaker) SetState(sol *StatefulOperationList) {
+	for _, i := range sol.FigureOperations {
+		if tweaker.ID != "" && i.ID != tweaker.ID {
+			continue
+		}
+		i.Center = tweaker.Position.Clamp()
+	}
+}
+
+// RemoveFigureTweaker видаляє фігуру з ідентифікатором ID.
+type RemoveFigureTweaker struct {
+	ID string
+}
+
+func (tweaker RemoveFigureTweaker) SetState(sol *StatefulOperationList) {
+	for i, f := range sol.FigureOperations {
+		if f.ID == tweaker.ID {
+			sol.FigureOperations = append(sol.FigureOperations[:i:i], sol.FigureOperations[i+1:]...)
+			return
+		}
 	}
 }
 
@@ -150,6 +299,9 @@ type ResetTweaker struct{}
 func (tweaker ResetTweaker) SetState(sol *StatefulOperationList) {
 	blackFillOperation := OperationFill{Color: color.Black}
 	sol.BgOperation = blackFillOperation
-	sol.BgRectOperation = nil
+	sol.BgRectOperations = nil
 	sol.FigureOperations = []*OperationFigure{}
+	sol.ImageOperations = nil
+	sol.TextOperations = nil
+	sol.figureSeq = 0
 }
@@ -0,0 +1,105 @@
+package Painter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/vector"
+)
+
+// fillContours зафарбовує область, яку охоплюють контури, у режимі mode. Контури растеризуються зі згладжуванням
+// у маску покриття, яку потім малює paintMask.
+func fillContours(t screen.Texture, contours [][]Vertex, c color.Color, mode BlendMode) {
+	bounds := t.Bounds().Intersect(contoursBounds(contours))
+	if bounds.Empty() {
+		return
+	}
+	paintMask(t, rasterize(contours, bounds), c, mode)
+}
+
+// paintMask зафарбовує пікселі текстури кольором c відповідно до маски покриття. Для текстур, які зберігаються
+// в пам'яті, маска змішується з фоном у буфері, який потім завантажується в текстуру через Upload. Інші текстури
+// прочитати неможливо, тому на них зафарбовуються лише пікселі, покриті щонайменше наполовину.
+func paintMask(t screen.Texture, mask *image.Alpha, c color.Color, mode BlendMode) {
+	bounds := mask.Rect
+	st, soft := t.(*SoftTexture)
+	if !soft {
+		fillMask(t, mask, c, mode)
+		return
+	}
+	buf := &softBuffer{rgba: image.NewRGBA(image.Rectangle{Max: bounds.Size()})}
+	draw.Draw(buf.rgba, buf.rgba.Rect, st.rgba, bounds.Min, draw.Src)
+	compose(buf.rgba, buf.rgba.Rect, c, mode, mask, bounds.Min)
+	t.Upload(bounds.Min, buf, buf.Bounds())
+}
+
+// rasterize повертає маску покриття контурів у межах bounds.
+func rasterize(contours [][]Vertex, bounds image.Rectangle) *image.Alpha {
+	z := vector.NewRasterizer(bounds.Dx(), bounds.Dy())
+	ox, oy := float32(bounds.Min.X), float32(bounds.Min.Y)
+	for _, contour := range contours {
+		if len(contour) < 3 {
+			continue
+		}
+		z.MoveTo(float32(contour[0].X)-ox, float32(contour[0].Y)-oy)
+		for _, v := range contour[1:] {
+			z.LineTo(float32(v.X)-ox, float32(v.Y)-oy)
+		}
+		z.ClosePath()
+	}
+	mask := image.NewAlpha(bounds)
+	z.Draw(mask, mask.Rect, image.Opaque, image.Point{})
+	return mask
+}
+
+// compose змішує колір c з пікселями dst у прямокутнику r відповідно до маски покриття, точка mp якої
+// відповідає r.Min.
+func compose(dst *image.RGBA, r image.Rectangle, c color.Color, mode BlendMode, mask *image.Alpha, mp image.Point) {
+	switch mode {
+	case BlendSrc:
+		draw.DrawMask(dst, r, image.NewUniform(c), image.Point{}, mask, mp, draw.Src)
+	case BlendOver:
+		draw.DrawMask(dst, r, image.NewUniform(c), image.Point{}, mask, mp, draw.Over)
+	default:
+		// Спочатку змішуємо колір з усім прямокутником, а потім переносимо результат пропорційно покриттю.
+		blended := image.NewRGBA(r)
+		draw.Draw(blended, r, dst, r.Min, draw.Src)
+		blendRect(blended, r, c, mode)
+		draw.DrawMask(dst, r, blended, r.Min, mask, mp, draw.Src)
+	}
+}
+
+// fillMask зафарбовує горизонтальними смугами пікселі, покриті маскою щонайменше наполовину.
+func fillMask(t screen.Texture, mask *image.Alpha, c color.Color, mode BlendMode) {
+	r := mask.Rect
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		start := -1
+		for x := r.Min.X; x <= r.Max.X; x++ {
+			covered := x < r.Max.X && mask.AlphaAt(x, y).A >= 0x80
+			if covered && start < 0 {
+				start = x
+			} else if !covered && start >= 0 {
+				fillRect(t, image.Rect(start, y, x, y+1), c, mode)
+				start = -1
+			}
+		}
+	}
+}
+
+func contoursBounds(contours [][]Vertex) image.Rectangle {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, contour := range contours {
+		for _, v := range contour {
+			minX, minY = min(minX, v.X), min(minY, v.Y)
+			maxX, maxY = max(maxX, v.X), max(maxY, v.Y)
+		}
+	}
+	if minX > maxX {
+		return image.Rectangle{}
+	}
+	return image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY)))
+}
@@ -0,0 +1,31 @@
+package Painter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// opaqueTexture приховує SoftTexture, щоб перевірити малювання на текстурах, які неможливо прочитати.
+type opaqueTexture struct {
+	*SoftTexture
+}
+
+func TestFillContours_AntiAliasing(t *testing.T) {
+	square := [][]Vertex{rectContour(10.5, 10, 20, 20)}
+	red := color.RGBA{R: 0xff, A: 0xff}
+
+	tx := NewSoftTexture(image.Pt(30, 30))
+	fillContours(tx, square, red, BlendOver)
+	assert.Equal(t, red, tx.RGBA().At(15, 15))
+	assert.Equal(t, color.RGBA{R: 0x80, A: 0x80}, tx.RGBA().At(10, 15))
+	assert.Equal(t, color.RGBA{}, tx.RGBA().At(9, 15))
+
+	fallback := opaqueTexture{NewSoftTexture(image.Pt(30, 30))}
+	fillContours(fallback, square, red, BlendOver)
+	assert.Equal(t, red, fallback.RGBA().At(15, 15))
+	assert.Equal(t, red, fallback.RGBA().At(10, 15))
+	assert.Equal(t, color.RGBA{}, fallback.RGBA().At(9, 15))
+}
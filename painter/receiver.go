@@ -0,0 +1,80 @@
+package Painter
+
+import (
+	"image"
+	"sync"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// Recorder зберігає копію останнього кадру, який було передано йому як Receiver. Кадри копіюються лише з текстур,
+// які зберігаються в пам'яті (див. SoftScreen).
+type Recorder struct {
+	mu    sync.Mutex
+	frame *image.RGBA
+}
+
+func (r *Recorder) Update(t screen.Texture) {
+	img, ok := Snapshot(t)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.frame = img
+}
+
+// Frame повертає останній збережений кадр або nil, якщо кадрів ще не було. Повернуте зображення не змінюється
+// наступними оновленнями.
+func (r *Recorder) Frame() *image.RGBA {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.frame
+}
+
+// Broadcaster розсилає копії отриманих кадрів усім підписникам. Підписник, який не встигає читати кадри,
+// отримує лише найновіший з них.
+type Broadcaster struct {
+	mu    sync.Mutex
+	subs  map[chan *image.RGBA]struct{}
+	frame *image.RGBA // останній кадр, який отримує кожен новий підписник
+}
+
+func (b *Broadcaster) Update(t screen.Texture) {
+	img, ok := Snapshot(t)
+	if !ok {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.frame = img
+	for ch := range b.subs {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- img
+	}
+}
+
+// Subscribe реєструє нового підписника. Повертає канал кадрів та функцію, яка скасовує підписку.
+func (b *Broadcaster) Subscribe() (<-chan *image.RGBA, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan *image.RGBA, 1)
+	if b.subs == nil {
+		b.subs = make(map[chan *image.RGBA]struct{})
+	}
+	b.subs[ch] = struct{}{}
+	if b.frame != nil {
+		ch <- b.frame
+	}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, ch)
+	}
+}
@@ -0,0 +1,161 @@
+package Painter
+
+import (
+	"image"
+	"math"
+)
+
+// Vertex точка в пікселях текстури.
+type Vertex struct {
+	X, Y float64
+}
+
+// Shape описує геометрію фігури відносно її центру.
+type Shape interface {
+	// Contours повертає замкнені контури фігури в пікселях для текстури розміру size, якщо центр фігури знаходиться
+	// в точці center. Зафарбовуються точки, для яких сумарна кількість обертів контурів навколо них не нульова.
+	Contours(center Vertex, size image.Point) [][]Vertex
+}
+
+// DefaultTSize розмір TShape за замовчуванням.
+const DefaultTSize = 0.125
+
+// TShape фігура у формі літери T. Size задає половину довжини горизонтальної планки відносно меншої зі сторін
+// текстури, а товщина планок становить 0.8 від Size. Якщо Size дорівнює нулю, використовується DefaultTSize.
+type TShape struct {
+	Size float64
+}
+
+func (s TShape) Contours(center Vertex, size image.Point) [][]Vertex {
+	rel := s.Size
+	if rel == 0 {
+		rel = DefaultTSize
+	}
+	hlen := rel * float64(min(size.X, size.Y))
+	hwidth := hlen * 0.8
+	return [][]Vertex{
+		rectContour(center.X-hlen, center.Y-hwidth, center.X+hlen, center.Y),
+		// Нижній вертикальний прямокутник
+		rectContour(center.X-hwidth/2, center.Y, center.X+hwidth/2, center.Y+hlen),
+	}
+}
+
+// Circle коло з радіусом R відносно меншої зі сторін текстури.
+type Circle struct {
+	R float64
+}
+
+func (s Circle) Contours(center Vertex, size image.Point) [][]Vertex {
+	r := s.R * float64(min(size.X, size.Y))
+	return [][]Vertex{ellipseContour(center, r, r)}
+}
+
+// Ellipse еліпс з піввісями RX та RY відносно ширини та висоти текстури відповідно.
+type Ellipse struct {
+	RX, RY float64
+}
+
+func (s Ellipse) Contours(center Vertex, size image.Point) [][]Vertex {
+	return [][]Vertex{ellipseContour(center, s.RX*float64(size.X), s.RY*float64(size.Y))}
+}
+
+// DefaultLineWidth товщина ліній Polyline за замовчуванням відносно меншої зі сторін текстури.
+const DefaultLineWidth = 0.005
+
+// Polyline ламана лінія, вершини якої задані зміщеннями від центру фігури. Лінія з двома вершинами є відрізком.
+// Якщо Width дорівнює нулю, використовується DefaultLineWidth.
+type Polyline struct {
+	Points []RelativePoint
+	Width  float64
+}
+
+func (s Polyline) Contours(center Vertex, size image.Point) [][]Vertex {
+	width := s.Width
+	if width == 0 {
+		width = DefaultLineWidth
+	}
+	hw := width * float64(min(size.X, size.Y)) / 2
+
+	points := offsetVertices(center, size, s.Points)
+	var res [][]Vertex
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		dx, dy := b.X-a.X, b.Y-a.Y
+		l := math.Hypot(dx, dy)
+		if l == 0 {
+			continue
+		}
+		// Нормаль до відрізка завдовжки в половину товщини лінії.
+		nx, ny := -dy/l*hw, dx/l*hw
+		res = append(res, orient([]Vertex{
+			{a.X + nx, a.Y + ny},
+			{b.X + nx, b.Y + ny},
+			{b.X - nx, b.Y - ny},
+			{a.X - nx, a.Y - ny},
+		}))
+	}
+	return res
+}
+
+// Polygon багатокутник, вершини якого задані зміщеннями від центру фігури. Трикутник є багатокутником з трьома
+// вершинами.
+type Polygon struct {
+	Points []RelativePoint
+}
+
+func (s Polygon) Contours(center Vertex, size image.Point) [][]Vertex {
+	return [][]Vertex{offsetVertices(center, size, s.Points)}
+}
+
+// Centroid повертає центр мас вершин і зміщення вершин від нього. Використовується, щоб задати Polyline чи Polygon
+// за абсолютними координатами вершин.
+func Centroid(points []RelativePoint) (RelativePoint, []RelativePoint) {
+	var c RelativePoint
+	for _, p := range points {
+		c.X += p.X / float64(len(points))
+		c.Y += p.Y / float64(len(points))
+	}
+	offsets := make([]RelativePoint, len(points))
+	for i, p := range points {
+		offsets[i] = RelativePoint{X: p.X - c.X, Y: p.Y - c.Y}
+	}
+	return c, offsets
+}
+
+func offsetVertices(center Vertex, size image.Point, offsets []RelativePoint) []Vertex {
+	res := make([]Vertex, len(offsets))
+	for i, p := range offsets {
+		res[i] = Vertex{X: center.X + p.X*float64(size.X), Y: center.Y + p.Y*float64(size.Y)}
+	}
+	return res
+}
+
+func rectContour(x0, y0, x1, y1 float64) []Vertex {
+	return []Vertex{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}
+}
+
+func ellipseContour(center Vertex, rx, ry float64) []Vertex {
+	// Кількість сегментів підбирається так, щоб довжина кожного була близько 4 пікселів.
+	n := min(max(int(2*math.Pi*max(rx, ry)/4), 16), 512)
+	res := make([]Vertex, n)
+	for i := range res {
+		a := 2 * math.Pi * float64(i) / float64(n)
+		res[i] = Vertex{X: center.X + rx*math.Cos(a), Y: center.Y + ry*math.Sin(a)}
+	}
+	return res
+}
+
+// orient повертає контур з додатною орієнтованою площею, щоб контури, які перекриваються, не утворювали дірок.
+func orient(contour []Vertex) []Vertex {
+	var area float64
+	for i, a := range contour {
+		b := contour[(i+1)%len(contour)]
+		area += a.X*b.Y - b.X*a.Y
+	}
+	if area < 0 {
+		for i, j := 0, len(contour)-1; i < j; i, j = i+1, j-1 {
+			contour[i], contour[j] = contour[j], contour[i]
+		}
+	}
+	return contour
+}
@@ -0,0 +1,81 @@
+package Painter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperationFigure_Shapes(t *testing.T) {
+	red := color.RGBA{R: 0xff, A: 0xff}
+	type testCase struct {
+		name    string
+		op      OperationFigure
+		inside  []image.Point
+		outside []image.Point
+	}
+	testTable := []testCase{
+		{
+			name:    "T shape",
+			op:      OperationFigure{Center: RelativePoint{X: 0.5, Y: 0.5}},
+			inside:  []image.Point{{150, 160}, {249, 199}, {180, 249}, {219, 200}},
+			outside: []image.Point{{149, 180}, {250, 180}, {179, 220}, {220, 220}, {200, 250}},
+		},
+		{
+			name:    "Circle",
+			op:      OperationFigure{Center: RelativePoint{X: 0.5, Y: 0.5}, Shape: Circle{R: 0.25}},
+			inside:  []image.Point{{200, 200}, {297, 200}, {200, 103}, {260, 260}},
+			outside: []image.Point{{301, 200}, {290, 290}, {200, 98}},
+		},
+		{
+			name:    "Ellipse",
+			op:      OperationFigure{Center: RelativePoint{X: 0.5, Y: 0.5}, Shape: Ellipse{RX: 0.25, RY: 0.1}},
+			inside:  []image.Point{{200, 200}, {295, 200}, {200, 165}},
+			outside: []image.Point{{200, 145}, {305, 200}},
+		},
+		{
+			name: "Polygon",
+			op: OperationFigure{Center: RelativePoint{X: 0.5, Y: 0.5}, Shape: Polygon{
+				Points: []RelativePoint{{X: -0.25, Y: 0.25}, {X: 0, Y: -0.25}, {X: 0.25, Y: 0.25}},
+			}},
+			inside:  []image.Point{{200, 110}, {110, 298}, {290, 298}},
+			outside: []image.Point{{120, 120}, {280, 120}, {200, 301}},
+		},
+		{
+			name: "Polyline",
+			op: OperationFigure{Center: RelativePoint{X: 0.5, Y: 0.5}, Shape: Polyline{
+				Points: []RelativePoint{{X: -0.25, Y: 0}, {X: 0.25, Y: 0}},
+				Width:  0.02,
+			}},
+			inside:  []image.Point{{100, 196}, {200, 203}, {299, 200}},
+			outside: []image.Point{{200, 190}, {200, 210}, {99, 200}, {301, 200}},
+		},
+	}
+
+	for _, test := range testTable {
+		tx := NewSoftTexture(image.Pt(400, 400))
+		test.op.Color = red
+		test.op.Do(tx)
+		for _, p := range test.inside {
+			assert.Equal(t, red, tx.RGBA().At(p.X, p.Y), "%s: %v should be filled", test.name, p)
+		}
+		for _, p := range test.outside {
+			assert.Equal(t, color.RGBA{}, tx.RGBA().At(p.X, p.Y), "%s: %v should be empty", test.name, p)
+		}
+	}
+}
+
+func TestTShape_ResolutionIndependent(t *testing.T) {
+	small := TShape{}.Contours(Vertex{X: 200, Y: 200}, image.Pt(400, 400))
+	large := TShape{}.Contours(Vertex{X: 400, Y: 400}, image.Pt(800, 800))
+	for i := range small {
+		for j := range small[i] {
+			assert.Equal(t, Vertex{X: small[i][j].X * 2, Y: small[i][j].Y * 2}, large[i][j])
+		}
+	}
+
+	double := TShape{Size: 2 * DefaultTSize}.Contours(Vertex{}, image.Pt(400, 400))
+	assert.Equal(t, Vertex{X: -100, Y: -80}, double[0][0])
+}
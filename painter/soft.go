@@ -0,0 +1,89 @@
+package Painter
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// SoftScreen реалізує screen.Screen у пам'яті без графічного драйвера. Текстури, які він створює, зберігають пікселі
+// в image.RGBA, тож Loop може працювати без дисплея, а отримані кадри можна перевірити програмно.
+type SoftScreen struct{}
+
+var errNoWindow = errors.New("soft screen does not support windows")
+
+func (SoftScreen) NewBuffer(size image.Point) (screen.Buffer, error) {
+	return &softBuffer{rgba: image.NewRGBA(image.Rectangle{Max: size})}, nil
+}
+
+func (SoftScreen) NewTexture(size image.Point) (screen.Texture, error) {
+	return NewSoftTexture(size), nil
+}
+
+func (SoftScreen) NewWindow(*screen.NewWindowOptions) (screen.Window, error) {
+	return nil, errNoWindow
+}
+
+// SoftTexture текстура, пікселі якої зберігаються в пам'яті.
+type SoftTexture struct {
+	rgba *image.RGBA
+}
+
+// NewSoftTexture створює прозору текстуру вказаного розміру.
+func NewSoftTexture(size image.Point) *SoftTexture {
+	return &SoftTexture{rgba: image.NewRGBA(image.Rectangle{Max: size})}
+}
+
+// RGBA повертає зображення, в яке малюється текстура.
+func (t *SoftTexture) RGBA() *image.RGBA { return t.rgba }
+
+func (t *SoftTexture) Release() {}
+
+func (t *SoftTexture) Size() image.Point { return t.rgba.Rect.Size() }
+
+func (t *SoftTexture) Bounds() image.Rectangle { return t.rgba.Rect }
+
+func (t *SoftTexture) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle) {
+	dr := sr.Sub(sr.Min).Add(dp)
+	draw.Draw(t.rgba, dr, src.RGBA(), sr.Min, draw.Src)
+}
+
+func (t *SoftTexture) Fill(dr image.Rectangle, src color.Color, op draw.Op) {
+	draw.Draw(t.rgba, dr, image.NewUniform(src), image.Point{}, op)
+}
+
+// Snapshot повертає копію пікселів текстури. Другий результат false, якщо текстура не зберігається в пам'яті
+// і прочитати її неможливо.
+func Snapshot(t screen.Texture) (*image.RGBA, bool) {
+	st, ok := t.(*SoftTexture)
+	if !ok {
+		return nil, false
+	}
+	img := image.NewRGBA(st.rgba.Rect)
+	copy(img.Pix, st.rgba.Pix)
+	return img, true
+}
+
+// copyTexture повертає копію текстури, яка зберігається в пам'яті. Інші текстури повертаються без змін.
+func copyTexture(t screen.Texture) screen.Texture {
+	img, ok := Snapshot(t)
+	if !ok {
+		return t
+	}
+	return &SoftTexture{rgba: img}
+}
+
+type softBuffer struct {
+	rgba *image.RGBA
+}
+
+func (b *softBuffer) Release() {}
+
+func (b *softBuffer) Size() image.Point { return b.rgba.Rect.Size() }
+
+func (b *softBuffer) Bounds() image.Rectangle { return b.rgba.Rect }
+
+func (b *softBuffer) RGBA() *image.RGBA { return b.rgba }
@@ -0,0 +1,45 @@
+package Painter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/shiny/screen"
+)
+
+func TestSoftScreen_Loop(t *testing.T) {
+	frames := make(chan *image.RGBA, 1)
+	loop := Loop{
+		Receiver: ReceiverFunc(func(t screen.Texture) {
+			img, _ := Snapshot(t)
+			frames <- img
+		}),
+	}
+	loop.Start(SoftScreen{})
+
+	loop.Post(OperationFill{Color: color.White})
+	loop.Post(OperationBGRect{Min: RelativePoint{X: 0.25, Y: 0.25}, Max: RelativePoint{X: 0.75, Y: 0.75}})
+	loop.Post(UpdateOp)
+
+	img := <-frames
+	loop.StopAndWait()
+
+	assert.Equal(t, image.Rect(0, 0, 400, 400), img.Bounds())
+	assert.Equal(t, color.RGBAModel.Convert(color.White), img.At(10, 10))
+	assert.Equal(t, color.RGBAModel.Convert(color.Black), img.At(200, 200))
+}
+
+func TestSoftTexture_Upload(t *testing.T) {
+	s := SoftScreen{}
+	buf, _ := s.NewBuffer(image.Pt(2, 2))
+	red := color.RGBA{R: 0xff, A: 0xff}
+	buf.RGBA().Set(1, 1, red)
+
+	tx := NewSoftTexture(image.Pt(10, 10))
+	tx.Upload(image.Pt(5, 5), buf, buf.Bounds())
+
+	assert.Equal(t, red, tx.RGBA().At(6, 6))
+	assert.Equal(t, color.RGBA{}, tx.RGBA().At(5, 5))
+}
@@ -0,0 +1,107 @@
+package Painter
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sync"
+
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// OperationText малює рядок Text шрифтом Go Regular. Position задає початок базової лінії тексту, а Size висоту
+// шрифту відносно висоти текстури. Якщо колір не вказано, текст малюється чорним.
+type OperationText struct {
+	Position RelativePoint
+	Size     float64
+	Color    color.Color
+	Text     string
+	Blend    BlendMode
+}
+
+func (op OperationText) Do(t screen.Texture) bool {
+	size := t.Size()
+	// Розмір округлюється до цілих пікселів, щоб кеш шрифтів не розростався від майже однакових розмірів.
+	px := math.Round(op.Size * float64(size.Y))
+	if px < 1 || op.Text == "" {
+		return false
+	}
+	c := op.Color
+	if c == nil {
+		c = color.Black
+	}
+	dot := fixed.Point26_6{
+		X: fixed.Int26_6(op.Position.X * float64(size.X) * 64),
+		Y: fixed.Int26_6(op.Position.Y * float64(size.Y) * 64),
+	}
+
+	textMu.Lock()
+	face, err := textFace(px)
+	if err != nil {
+		textMu.Unlock()
+		return false
+	}
+	b, _ := font.BoundString(face, op.Text)
+	bounds := image.Rect(
+		(dot.X + b.Min.X).Floor(), (dot.Y + b.Min.Y).Floor(),
+		(dot.X + b.Max.X).Ceil(), (dot.Y + b.Max.Y).Ceil(),
+	).Intersect(t.Bounds())
+	if bounds.Empty() {
+		textMu.Unlock()
+		return false
+	}
+	mask := image.NewAlpha(bounds)
+	d := font.Drawer{Dst: mask, Src: image.Opaque, Face: face, Dot: dot}
+	d.DrawString(op.Text)
+	textMu.Unlock()
+
+	paintMask(t, mask, c, op.Blend)
+	return false
+}
+
+func (op OperationText) SetState(sol *StatefulOperationList) {
+	sol.TextOperations = append(sol.TextOperations, op)
+}
+
+// maxTextFaces кількість шрифтів різних розмірів, які зберігаються в кеші.
+const maxTextFaces = 32
+
+var (
+	// textMu захищає кеш шрифтів: font.Face не можна використовувати з кількох горутин одночасно.
+	textMu    sync.Mutex
+	textFont  *opentype.Font
+	textFaces = map[float64]font.Face{}
+	// textSizes розміри шрифтів у кеші в порядку їх створення.
+	textSizes []float64
+)
+
+// textFace повертає шрифт вказаного розміру в пікселях. Якщо кеш заповнений, з нього видаляється найстаріший
+// шрифт. Потребує захоплення textMu.
+func textFace(px float64) (font.Face, error) {
+	if face, ok := textFaces[px]; ok {
+		return face, nil
+	}
+	if textFont == nil {
+		f, err := opentype.Parse(goregular.TTF)
+		if err != nil {
+			return nil, err
+		}
+		textFont = f
+	}
+	face, err := opentype.NewFace(textFont, &opentype.FaceOptions{Size: px, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return nil, err
+	}
+	if len(textSizes) >= maxTextFaces {
+		_ = textFaces[textSizes[0]].Close()
+		delete(textFaces, textSizes[0])
+		textSizes = textSizes[1:]
+	}
+	textFaces[px] = face
+	textSizes = append(textSizes, px)
+	return face, nil
+}
@@ -0,0 +1,45 @@
+package Painter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperationText_Do(t *testing.T) {
+	red := color.RGBA{R: 0xff, A: 0xff}
+	tx := NewSoftTexture(image.Pt(200, 100))
+	op := OperationText{Position: RelativePoint{X: 0.1, Y: 0.5}, Size: 0.2, Color: red, Text: "HI"}
+	op.Do(tx)
+
+	painted := image.Rectangle{}
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			if tx.RGBA().RGBAAt(x, y) == red {
+				painted = painted.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	// Літери стоять на базовій лінії, тож текст знаходиться над нею та починається від заданої позиції.
+	assert.False(t, painted.Empty())
+	assert.GreaterOrEqual(t, painted.Min.X, 20)
+	assert.LessOrEqual(t, painted.Max.Y, 50)
+	assert.Greater(t, painted.Dy(), 10)
+	assert.Equal(t, color.RGBA{}, tx.RGBA().RGBAAt(10, 75))
+}
+
+func TestOperationText_FaceCache(t *testing.T) {
+	tx := NewSoftTexture(image.Pt(200, 1000))
+	for i := 0; i < 2*maxTextFaces; i++ {
+		// Розміри, які відрізняються менше ніж на піксель, використовують один шрифт.
+		OperationText{Size: 0.01 + float64(i)*0.001 + 0.0001, Text: "a"}.Do(tx)
+		OperationText{Size: 0.01 + float64(i)*0.001 + 0.0002, Text: "a"}.Do(tx)
+	}
+	textMu.Lock()
+	defer textMu.Unlock()
+	assert.Len(t, textFaces, maxTextFaces)
+	assert.Len(t, textSizes, maxTextFaces)
+	assert.Equal(t, float64(10+2*maxTextFaces-1), textSizes[maxTextFaces-1])
+}
@@ -0,0 +1,13 @@
+#!/bin/bash
+
+send_command() {
+    curl -X POST -d "$1" http://localhost:17000
+}
+
+# Той самий рух, що й у diagonal.sh, але проміжні кадри малює сервер. Анімації починаються з командою update.
+send_command "green
+figure f 1 0
+update
+animate f to 0 1 over 10s easing ease-in-out
+animate f to 1 0 over 10s
+update"
@@ -12,7 +12,7 @@ send_command "green"
 send_command "figure $x $y"
 
 while true; do
-    send_command "move $x $y"
+    send_command "moveto $x $y"
     x=$(awk "BEGIN {printf \"%.2f\", $x - $step}")
     y=$(awk "BEGIN {printf \"%.2f\", $y + $step}")
 
@@ -19,6 +19,8 @@ type Visualizer struct {
 	Title         string
 	Debug         bool
 	OnScreenReady func(s screen.Screen)
+	// Розмір вікна. Якщо не вказано, використовується 800x800.
+	Width, Height int
 
 	w    screen.Window
 	tx   chan screen.Texture
@@ -26,30 +28,49 @@ type Visualizer struct {
 
 	sz  size.Event
 	pos image.Rectangle
+
+	buf screen.Buffer  // буфер для перенесення текстур, які зберігаються в пам'яті
+	tex screen.Texture // текстура драйвера, в яку переноситься вміст buf
+}
+
+// rgbaTexture реалізують текстури, пікселі яких можна прочитати (наприклад, створені Painter.SoftScreen).
+type rgbaTexture interface {
+	RGBA() *image.RGBA
 }
 
 func (pw *Visualizer) Main() {
 	pw.tx = make(chan screen.Texture)
 	pw.done = make(chan struct{})
-	pw.pos.Max.X = 400
-	pw.pos.Max.Y = 400
+	if pw.Width == 0 || pw.Height == 0 {
+		pw.Width, pw.Height = 800, 800
+	}
+	pw.pos.Max.X = pw.Width / 2
+	pw.pos.Max.Y = pw.Height / 2
 	driver.Main(pw.run)
 }
 
+// Update передає текстуру вікну. Після закриття вікна текстури відкидаються, щоб цикл міг завершитися.
 func (pw *Visualizer) Update(t screen.Texture) {
-	pw.tx <- t
+	select {
+	case pw.tx <- t:
+	case <-pw.done:
+	}
 }
 
 func (pw *Visualizer) run(s screen.Screen) {
 	w, err := s.NewWindow(&screen.NewWindowOptions{
 		Title:  pw.Title,
-		Width:  800,
-		Height: 800,
+		Width:  pw.Width,
+		Height: pw.Height,
 	})
 	if err != nil {
 		log.Fatal("Failed to initialize the app window:", err)
 	}
 	defer func() {
+		if pw.buf != nil {
+			pw.buf.Release()
+			pw.tex.Release()
+		}
 		w.Release()
 		close(pw.done)
 	}()
@@ -86,11 +107,44 @@ func (pw *Visualizer) run(s screen.Screen) {
 			pw.handleEvent(e, t)
 
 		case t = <-pw.tx:
+			t = pw.present(s, t)
 			w.Send(paint.Event{})
 		}
 	}
 }
 
+// present переносить текстуру, яка зберігається в пам'яті, у текстуру драйвера, щоб її можна було вивести у вікно.
+// Текстури драйвера повертаються без змін.
+func (pw *Visualizer) present(s screen.Screen, t screen.Texture) screen.Texture {
+	src, ok := t.(rgbaTexture)
+	if !ok {
+		return t
+	}
+	img := src.RGBA()
+	if pw.buf == nil || pw.buf.Size() != img.Rect.Size() {
+		if pw.buf != nil {
+			pw.buf.Release()
+			pw.tex.Release()
+			pw.buf, pw.tex = nil, nil
+		}
+		buf, err := s.NewBuffer(img.Rect.Size())
+		if err != nil {
+			log.Printf("ERROR: %s", err)
+			return nil
+		}
+		tex, err := s.NewTexture(img.Rect.Size())
+		if err != nil {
+			buf.Release()
+			log.Printf("ERROR: %s", err)
+			return nil
+		}
+		pw.buf, pw.tex = buf, tex
+	}
+	draw.Draw(pw.buf.RGBA(), pw.buf.Bounds(), img, img.Rect.Min, draw.Src)
+	pw.tex.Upload(image.Point{}, pw.buf, pw.buf.Bounds())
+	return pw.tex
+}
+
 func detectTerminate(e any) bool {
 	switch e := e.(type) {
 	case lifecycle.Event:
@@ -140,7 +194,7 @@ func (pw *Visualizer) drawDefaultUI() {
 func (pw *Visualizer) DrawFigure(x, y int) {
 	Length := 70
 	Width := 30
-	blue := color.RGBA{R: 0, G: 54, B: 206, A: 1}
+	blue := color.RGBA{R: 0, G: 54, B: 206, A: 0xff}
 
 	horizontal := image.Rect(x-Length, y-Length, x+Length, y-Length+Width*2)
 	pw.w.Fill(horizontal, blue, draw.Src)